@@ -0,0 +1,186 @@
+// Command confdump prints the effective configuration, after includes, macros, constants and claims.
+//
+// Usage:
+//
+//	confdump [flags] file.conf
+//
+// With -annotate, every property tells where it got its value, and sects skipped because of
+// claims are listed with the claims that were not fulfilled
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/grufgran/config"
+	confContext "github.com/grufgran/config/context"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	ctx := confContext.NewContext(nil)
+	fs := flag.NewFlagSet("confdump", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	confFlags := config.NewFlags(fs, ctx)
+	format := fs.String("format", "ini", "output format: ini, json or yaml")
+	annotate := fs.Bool("annotate", false, "tell where values came from and which sects were skipped")
+	fs.StringVar(&ctx.EnvOverridePrefix, "env-prefix", "", "apply environment overrides like <prefix>__SECT__PROP")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: confdump [flags] file.conf")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	fileName := fs.Arg(0)
+	if err := ctx.SetConfRoot(fileName); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	conf, err := config.NewConfigFromFile(ctx, fileName, nil)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	confFlags.Apply(conf)
+
+	switch *format {
+	case "ini":
+		if *annotate {
+			_, err = conf.WriteAnnotatedTo(stdout)
+		} else {
+			_, err = conf.WriteTo(stdout)
+		}
+	case "json":
+		err = writeJSON(stdout, conf, *annotate)
+	case "yaml":
+		err = writeYAML(stdout, conf, *annotate)
+	default:
+		fmt.Fprintf(stderr, "unknown format %v\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// a sect skipped because of claims
+type skipped struct {
+	Sect   string `json:"sect"`
+	Claims string `json:"claims"`
+	Origin string `json:"origin"`
+}
+
+func skippedSects(conf *config.Config) []skipped {
+	list := make([]skipped, 0)
+	for _, s := range conf.SkippedSects() {
+		list = append(list, skipped{Sect: s.Name, Claims: s.Claims, Origin: s.Origin.String()})
+	}
+	return list
+}
+
+// write {"sects": {...}}, and with annotate also "origins" and "skipped"
+func writeJSON(w io.Writer, conf *config.Config, annotate bool) error {
+	// conf keeps the order of sects and props
+	out := map[string]any{"sects": conf}
+	if annotate {
+		origins := make(map[string]map[string]string)
+		for sectName, props := range conf.GetSects() {
+			origins[sectName] = make(map[string]string, len(props))
+			for propName := range props {
+				if origin := conf.Sect(sectName).Prop(propName).Origin(); origin != nil {
+					origins[sectName][propName] = origin.String()
+				}
+			}
+		}
+		out["origins"] = origins
+		out["skipped"] = skippedSects(conf)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// write one mapping per sect. Strings are double quoted with yaml escapes
+func writeYAML(w io.Writer, conf *config.Config, annotate bool) error {
+	bw := bufio.NewWriter(w)
+	// sects without props are written too, like by WriteTo
+	seen := make(map[string]bool)
+	for _, sectName := range conf.SectNames() {
+		if seen[sectName] {
+			continue
+		}
+		seen[sectName] = true
+		sect := conf.Sect(sectName)
+		fmt.Fprintf(bw, "%v:", yamlQuote(sectName))
+		if len(sect.PropNames()) == 0 {
+			fmt.Fprint(bw, " {}")
+		}
+		fmt.Fprintln(bw)
+		sect.Range(func(prop *config.Prop) bool {
+			value, _ := prop.Value()
+			fmt.Fprintf(bw, "  %v: %v", yamlQuote(prop.Name()), yamlQuote(value))
+			if origin := prop.Origin(); annotate && origin != nil {
+				fmt.Fprintf(bw, " # %v", origin)
+			}
+			fmt.Fprintln(bw)
+			return true
+		})
+	}
+	if annotate {
+		for _, s := range skippedSects(conf) {
+			fmt.Fprintf(bw, "# skipped [%v] at %v, claims %v not fulfilled\n", s.Sect, s.Origin, s.Claims)
+		}
+	}
+	return bw.Flush()
+}
+
+// quote s as a yaml double quoted scalar. Printable runes are written as they are and others are escaped.
+// Invalid utf-8 can't be written in yaml, and becomes U+FFFD
+func yamlQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteRune('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r <= 0xFFFF:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			fmt.Fprintf(&sb, `\U%08X`, r)
+		}
+	}
+	sb.WriteRune('"')
+	return sb.String()
+}
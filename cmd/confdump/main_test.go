@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"testdata/dump.ini.golden", []string{"testdata/dump.conf"}},
+		{"testdata/dump.yaml.golden", []string{"-format", "yaml", "testdata/dump.conf"}},
+		{"testdata/dump.json.golden", []string{"-format", "json", "testdata/dump.conf"}},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%v: exit code %v: %v", test.args, code, stderr.String())
+		}
+		if *update {
+			if err := os.WriteFile(test.golden, stdout.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := os.ReadFile(test.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != string(expected) {
+			t.Errorf("%v: output differs from %v:\n%v", test.args, test.golden, stdout.String())
+		}
+	}
+}
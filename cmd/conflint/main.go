@@ -0,0 +1,237 @@
+// Command conflint checks a conf file, and all files it includes, for problems.
+//
+// Usage:
+//
+//	conflint [flags] file.conf
+//
+// Errors are syntax errors, unresolved constants, undefined macros, missing includes and schema
+// violations. Warnings are unused macros, duplicate keys and missing optional includes. Sects skipped
+// by claims are reported as info. The exit code is 0 if no errors were found, 1 if there were errors
+// (or warnings with -strict), 2 if conflint was used the wrong way and 3 if the result could not be written
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/grufgran/config"
+	confContext "github.com/grufgran/config/context"
+)
+
+const (
+	exitOK = iota
+	exitProblems
+	exitUsage
+	exitError
+)
+
+type severity string
+
+const (
+	sevError   severity = "error"
+	sevWarning severity = "warning"
+	sevInfo    severity = "info"
+)
+
+// one problem found
+type diagnostic struct {
+	Severity     severity `json:"severity"`
+	Kind         string   `json:"kind"`
+	File         string   `json:"file,omitempty"`
+	Line         int      `json:"line,omitempty"`
+	Column       int      `json:"column,omitempty"`
+	IncludedFrom []string `json:"includedFrom,omitempty"`
+	Message      string   `json:"message"`
+}
+
+// the json output
+type result struct {
+	File        string        `json:"file"`
+	Errors      int           `json:"errors"`
+	Warnings    int           `json:"warnings"`
+	Diagnostics []*diagnostic `json:"diagnostics"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	ctx := confContext.NewContext(nil)
+	fs := flag.NewFlagSet("conflint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	confFlags := config.NewFlags(fs, ctx)
+	jsonOutput := fs.Bool("json", false, "write result as json")
+	strict := fs.Bool("strict", false, "exit with 1 on warnings too")
+	schemaFile := fs.String("schema", "", "validate against schema file")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: conflint [flags] file.conf")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	fileName := fs.Arg(0)
+	if err := ctx.SetConfRoot(fileName); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	res := &result{File: fileName, Diagnostics: make([]*diagnostic, 0)}
+	ctx.Lenient = true
+	conf, err := config.NewConfigFromFile(ctx, fileName, nil)
+	res.addErr(err)
+	confFlags.Apply(conf)
+	res.addReport(conf)
+
+	// schema violations
+	if *schemaFile != "" {
+		schema, err := config.LoadSchema(nil, *schemaFile, nil)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		res.addValidation(conf.Validate(schema))
+	}
+
+	for _, d := range res.Diagnostics {
+		switch d.Severity {
+		case sevError:
+			res.Errors++
+		case sevWarning:
+			res.Warnings++
+		}
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(res)
+	} else {
+		err = res.print(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if res.Errors > 0 || (*strict && res.Warnings > 0) {
+		return exitProblems
+	}
+	return exitOK
+}
+
+// add parse errors
+func (res *result) addErr(err error) {
+	if err == nil {
+		return
+	}
+	var errs config.ParseErrors
+	var pe *config.ParseError
+	switch {
+	case errors.As(err, &errs):
+		for _, pe := range errs {
+			res.addParseError(pe)
+		}
+	case errors.As(err, &pe):
+		res.addParseError(pe)
+	default:
+		res.add(&diagnostic{Severity: sevError, Kind: "io", Message: err.Error()})
+	}
+}
+
+func (res *result) addParseError(pe *config.ParseError) {
+	res.add(&diagnostic{
+		Severity:     sevError,
+		Kind:         pe.Kind.String(),
+		File:         pe.FileName,
+		Line:         pe.Line,
+		Column:       pe.Column,
+		IncludedFrom: pe.IncludedFrom,
+		Message:      pe.Err.Error(),
+	})
+}
+
+// add warnings and infos noticed while parsing
+func (res *result) addReport(conf *config.Config) {
+	for _, m := range conf.UnusedMacros() {
+		res.addAt(sevWarning, "unused-macro", m.Origin, fmt.Sprintf("macro %v is never used", m.Name))
+	}
+	for _, d := range conf.DuplicateProps() {
+		res.addAt(sevWarning, "duplicate", d.Origin, fmt.Sprintf("[%v] %v is already set at %v", d.Sect, d.Prop, d.Previous))
+	}
+	for _, m := range conf.MissingIncludes() {
+		res.addAt(sevWarning, "missing-include", m.Origin, fmt.Sprintf("optional include %v does not exist", m.FileName))
+	}
+	for _, s := range conf.SkippedSects() {
+		res.addAt(sevInfo, "skipped-sect", s.Origin, fmt.Sprintf("[%v] skipped, claims %v not fulfilled", s.Name, s.Claims))
+	}
+}
+
+// add schema violations
+func (res *result) addValidation(err error) {
+	var errs config.ValidationErrors
+	if !errors.As(err, &errs) {
+		res.addErr(err)
+		return
+	}
+	for _, v := range errs {
+		msg := fmt.Sprintf("[%v] %v", v.Sect, v.Msg)
+		if v.Prop != "" {
+			msg = fmt.Sprintf("[%v] %v: %v", v.Sect, v.Prop, v.Msg)
+		}
+		res.add(&diagnostic{Severity: sevError, Kind: "schema", File: v.FileName, Line: v.Row, Message: msg})
+	}
+}
+
+func (res *result) addAt(sev severity, kind string, origin *config.Origin, msg string) {
+	d := &diagnostic{Severity: sev, Kind: kind, Message: msg}
+	if origin != nil {
+		d.File = origin.FileName
+		d.Line = origin.RowNumber
+		d.IncludedFrom = origin.IncludedFrom
+	}
+	res.add(d)
+}
+
+func (res *result) add(d *diagnostic) {
+	res.Diagnostics = append(res.Diagnostics, d)
+}
+
+// write diagnostics like file:line:column: severity: message [kind]
+func (res *result) print(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	for _, d := range res.Diagnostics {
+		if d.File != "" {
+			fmt.Fprintf(w, "%v:%v:", relPath(d.File), d.Line)
+			if d.Column > 0 {
+				fmt.Fprintf(w, "%v:", d.Column)
+			}
+			fmt.Fprint(w, " ")
+		}
+		fmt.Fprintf(w, "%v: %v [%v]\n", d.Severity, d.Message, d.Kind)
+	}
+	fmt.Fprintf(w, "%v errors, %v warnings\n", res.Errors, res.Warnings)
+	return w.Flush()
+}
+
+// file name relative to the current directory, if possible
+func relPath(fileName string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return fileName
+	}
+	if rel, err := filepath.Rel(wd, fileName); err == nil {
+		return rel
+	}
+	return fileName
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"testdata/lint.golden", []string{"testdata/lint.conf"}},
+		{"testdata/lint.json.golden", []string{"-json", "testdata/lint.conf"}},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != exitProblems {
+			t.Fatalf("%v: exit code %v: %v", test.args, code, stderr.String())
+		}
+		// json output has absolute file names
+		output := strings.ReplaceAll(stdout.String(), wd+string(filepath.Separator), "")
+		if *update {
+			if err := os.WriteFile(test.golden, []byte(output), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := os.ReadFile(test.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != string(expected) {
+			t.Errorf("%v: output differs from %v:\n%v", test.args, test.golden, output)
+		}
+	}
+}
+
+// writer that always fails
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriteError(t *testing.T) {
+	for _, args := range [][]string{{"testdata/lint.conf"}, {"-json", "testdata/lint.conf"}} {
+		var stderr bytes.Buffer
+		if code := run(args, failingWriter{}, &stderr); code != exitError {
+			t.Errorf("%v: expected exit code %v, got %v", args, exitError, code)
+		}
+	}
+}
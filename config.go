@@ -1,7 +1,9 @@
 package config
 
 import (
-	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"strings"
 
 	confContext "github.com/grufgran/config/context"
@@ -12,28 +14,44 @@ import (
 type Config struct {
 	sectNames []string
 	sects     map[string]map[string]string
+	// props of each sect, in the order they were first set
+	propNames map[string][]string
+	// all values of props set more than once, with the DuplicateAccumulate policy
+	values map[string]map[string][]string
+	// items of list props, like key = [a, b]
+	lists     map[string]map[string][]string
 	macros    map[string]*macro
 	filesUsed []string
+	// optional includes that did not exist when parsing
+	filesMissing []string
+	origins      map[string]map[string]*Origin
+	// errors collected in lenient mode
+	parseErrors ParseErrors
+	report      parseReport
+	// logger used while parsing, for trace events deep down
+	logger *Logger
+	// schema enforced by SetProp and friends, if set
+	schema *Schema
 }
 
 func NewConfig() *Config {
 	conf := &Config{
-		sects: make(map[string]map[string]string),
+		sects:     make(map[string]map[string]string),
+		propNames: make(map[string][]string),
+		values:    make(map[string]map[string][]string),
+		lists:     make(map[string]map[string][]string),
+		macros:    make(map[string]*macro),
+		origins:   make(map[string]map[string]*Origin),
 	}
 	return conf
 }
 
+// Read config from fileName. A backslash before #, [, ], $ or another backslash is an escape, and is removed
+// when the value is read. So \# is a # that does not start a comment, and \\ is read as one backslash
 func NewConfigFromFile(ctx *confContext.Context, fileName string, logger *Logger) (*Config, error) {
 
 	// create ctx if not provided
-	if ctx == nil {
-		ctx = confContext.NewContext(nil)
-		ctx.SetConfRoot(fileName)
-	} else {
-		if _, err := ctx.GetConfRoot(); err != nil {
-			ctx.SetConfRoot(fileName)
-		}
-	}
+	ctx = prepareContext(ctx, fileName)
 
 	// create conf
 	conf := NewConfig()
@@ -43,13 +61,71 @@ func NewConfigFromFile(ctx *confContext.Context, fileName string, logger *Logger
 	if err != nil {
 		return conf, err
 	}
+	conf.ApplyEnvOverrides(ctx)
 
 	// return
-	return conf, nil
+	return conf, conf.collectedErrors()
 }
 
+// Read config from r. Relative includes are resolved from the directory of fileName, which doesn't have to exist.
+// If fileName is empty, the current directory is used
+func NewConfigFromReader(ctx *confContext.Context, r io.Reader, fileName string, logger *Logger) (*Config, error) {
+
+	// without fileName, let the config live in the current directory
+	confRoot := fileName
+	if fileName == "" {
+		confRoot = "."
+		fileName = "<reader>"
+	}
+	ctx = prepareContext(ctx, confRoot)
+	absFilename, err := ctx.AbsPath(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := NewConfig()
+	if err := readConfig(ctx, r, absFilename, conf, logger); err != nil {
+		return conf, err
+	}
+	conf.ApplyEnvOverrides(ctx)
+	return conf, conf.collectedErrors()
+}
+
+// Read config from s. Relative includes are resolved from the current directory
+func NewConfigFromString(ctx *confContext.Context, s string, logger *Logger) (*Config, error) {
+	return NewConfigFromReader(ctx, strings.NewReader(s), "", logger)
+}
+
+// Read config from fileName within fsys, like an embed.FS. Includes are resolved within fsys, and ^/ is the
+// directory of fileName there. ctx is copied, so it still reads from its own file system afterwards
+func NewConfigFromFS(ctx *confContext.Context, fsys fs.FS, fileName string, logger *Logger) (*Config, error) {
+	if ctx == nil {
+		ctx = confContext.NewContext(nil)
+	} else {
+		ctx = ctx.Copy()
+		// a conf root in ctx is a path in the OS file system, so it is set again within fsys
+		delete(ctx.BasePaths, "^/")
+	}
+	ctx.SetFS(fsys)
+	return NewConfigFromFile(ctx, fileName, logger)
+}
+
+// create ctx if not provided, and make sure it has a conf root
+func prepareContext(ctx *confContext.Context, confRoot string) *confContext.Context {
+	if ctx == nil {
+		ctx = confContext.NewContext(nil)
+		ctx.SetConfRoot(confRoot)
+	} else {
+		if _, err := ctx.GetConfRoot(); err != nil {
+			ctx.SetConfRoot(confRoot)
+		}
+	}
+	return ctx
+}
+
+// Sect names in the order they were found. Changing the slice doesn't change conf
 func (conf *Config) SectNames() []string {
-	return conf.sectNames
+	return append([]string(nil), conf.sectNames...)
 }
 
 func (conf *Config) Sect(name string) *Sect {
@@ -89,7 +165,7 @@ func (conf *Config) replaceConstants(sm *stringMask.StringMask, currentMask, set
 	// Get squere brackets
 	leftSquereBrackets, rightSquereBrackets, err := sm.GetMaskPointsForOppositeRunes('[', ']', currentMask)
 	if err != nil {
-		return err
+		return newRowError(KindSyntax, -1, "%w", err)
 	}
 
 	// no squere brackets, no show
@@ -123,10 +199,11 @@ func (conf *Config) replaceConstants(sm *stringMask.StringMask, currentMask, set
 						// incredible, it was found!
 						sm.MaskBetween((*leftSquereBrackets)[minIndex].Pos, (*rightSquereBrackets)[minIndex].Pos, setMaskTo)
 						sm.NewTagAtPos((*leftSquereBrackets)[minIndex].Pos, val)
+						logEvent(conf.logger, slog.LevelDebug, "constant replaced", slog.String("section", sect), slog.String("key", prop), slog.String("value", val))
 						break
 					} else {
 						constant := sm.GetStringBetween((*leftSquereBrackets)[minIndex].Pos, (*colons)[i].Pos, false)
-						return fmt.Errorf("could not replace constant %v in %v. Constant value not found", constant, string(sm.String))
+						return newRowError(KindConstant, (*leftSquereBrackets)[minIndex].Pos, "could not replace constant %v in %v. Constant value not found", constant, string(sm.String))
 					}
 				}
 			}
@@ -141,7 +218,8 @@ func (conf *Config) replaceConstants(sm *stringMask.StringMask, currentMask, set
 	return nil
 }
 
-func (conf *Config) hasRequiredClaims(sm *stringMask.StringMask, currentMask, setMaskTo rune, ctx *confContext.Context) (bool, error) {
+// mask claims in a section row. If evaluate is false, the claims are only masked and considered fulfilled
+func (conf *Config) hasRequiredClaims(sm *stringMask.StringMask, currentMask, setMaskTo rune, ctx *confContext.Context, evaluate bool) (bool, error) {
 	// ok, so we have a section, and now its time to see if it has any claims.
 	// if there is a question mark on the row, then there are claims
 	questionMark := sm.GetFirstRunePoint('?', currentMask)
@@ -171,7 +249,7 @@ func (conf *Config) hasRequiredClaims(sm *stringMask.StringMask, currentMask, se
 
 	// There can not be both commas and ands
 	if len(*commas) > 0 && len(*ands) > 0 {
-		return false, fmt.Errorf("there can not be both \",\" (commas) and \"&\" in claims sektion: %s", string(sm.String))
+		return false, newRowError(KindClaims, (*ands)[0].Pos, "there can not be both \",\" (commas) and \"&\" in claims sektion: %s", string(sm.String))
 	}
 	// Mask whitespace around delimiters
 	sm.MaskLeftRightSpacesAroundPoints(commas, 'X', setMaskTo)
@@ -179,6 +257,9 @@ func (conf *Config) hasRequiredClaims(sm *stringMask.StringMask, currentMask, se
 
 	// get all claims
 	claims := sm.GetStrings(setMaskTo)
+	if !evaluate {
+		return true, nil
+	}
 
 	// Loop claims and replace constants
 	for i := range claims {
@@ -187,7 +268,7 @@ func (conf *Config) hasRequiredClaims(sm *stringMask.StringMask, currentMask, se
 			if val, exists := conf.sects[sect][prop]; exists {
 				claims[i] = val
 			} else {
-				return false, fmt.Errorf("could not replace constant %v in %v. Constant value not found", claims[i], string(sm.String))
+				return false, newRowError(KindConstant, -1, "could not replace constant %v in %v. Constant value not found", claims[i], string(sm.String))
 			}
 		}
 	}
@@ -228,17 +309,21 @@ func (c *Config) upsertProperty(ctx *confContext.Context, key, value string) {
 		// Get current section
 		cs := ctx.RunTime.Params[confContext.CurrSect]
 		if prop, exists := c.sects[cs]; exists {
+			if _, exists := prop[key]; !exists {
+				c.addPropName(cs, key)
+			}
 			prop[key] = value
 			c.sects[cs] = prop
 		} else {
 			c.sects[cs] = map[string]string{key: value}
+			c.addPropName(cs, key)
 		}
 	} else {
 		// Get current macro
 		cm := ctx.RunTime.Params[confContext.CurrMacro]
 		// always append to macro body
 		m := c.macros[cm]
-		m.properties[key] = value
+		m.setProperty(key, value)
 	}
 }
 
@@ -257,8 +342,12 @@ func (c *Config) appendProperty(ctx *confContext.Context, key string, values ...
 			}
 			sb.WriteString(values[0])
 			c.sects[cs][key] = sb.String()
+		} else if props, exists := c.sects[cs]; exists {
+			props[key] = values[0]
+			c.addPropName(cs, key)
 		} else {
 			c.sects[cs] = map[string]string{key: values[0]}
+			c.addPropName(cs, key)
 		}
 	} else {
 		// Get current macro
@@ -276,7 +365,7 @@ func (c *Config) appendProperty(ctx *confContext.Context, key string, values ...
 			sb.WriteString(values[0])
 			macro.properties[key] = sb.String()
 		} else {
-			macro.properties[key] = values[0]
+			macro.setProperty(key, values[0])
 		}
 	}
 }
@@ -289,29 +378,141 @@ func (conf *Config) getValuesLen(values ...string) int {
 	return l
 }
 
-func (conf *Config) addMacroPropsToSect(ctx *confContext.Context, macroName *string) error {
+// set property, creating the sect if needed
+func (conf *Config) setProp(sectName, key, value string, origin *Origin) {
+	if props, exists := conf.sects[sectName]; exists {
+		if _, exists := props[key]; !exists {
+			conf.addPropName(sectName, key)
+		}
+		props[key] = value
+	} else {
+		if !conf.hasSectName(sectName) {
+			conf.sectNames = append(conf.sectNames, sectName)
+		}
+		conf.sects[sectName] = map[string]string{key: value}
+		conf.addPropName(sectName, key)
+	}
+	conf.setOrigin(sectName, key, origin)
+	// the new value replaces all accumulated ones, and any list
+	delete(conf.values[sectName], key)
+	delete(conf.lists[sectName], key)
+}
+
+// remember the items of a list prop. Nil items means the prop isn't a list
+func (conf *Config) setListItems(sectName, key string, items []string) {
+	if items == nil {
+		delete(conf.lists[sectName], key)
+		return
+	}
+	if _, exists := conf.lists[sectName]; !exists {
+		conf.lists[sectName] = make(map[string][]string)
+	}
+	conf.lists[sectName][key] = items
+}
+
+// keep the current value of a property, together with the values it had before
+func (conf *Config) accumulateValue(sectName, key, prevValue string) {
+	if _, exists := conf.values[sectName]; !exists {
+		conf.values[sectName] = make(map[string][]string)
+	}
+	values, exists := conf.values[sectName][key]
+	if !exists {
+		values = []string{prevValue}
+	}
+	conf.values[sectName][key] = append(values, conf.sects[sectName][key])
+}
+
+// check if sectName is in sectNames
+func (conf *Config) hasSectName(sectName string) bool {
+	for _, name := range conf.sectNames {
+		if name == sectName {
+			return true
+		}
+	}
+	return false
+}
+
+// remove property and everything known about it
+func (conf *Config) deleteProperty(sectName, key string) {
+	delete(conf.sects[sectName], key)
+	delete(conf.origins[sectName], key)
+	delete(conf.values[sectName], key)
+	delete(conf.lists[sectName], key)
+	conf.removePropName(sectName, key)
+}
+
+// remember that key was set in sect for the first time
+func (conf *Config) addPropName(sectName, key string) {
+	conf.propNames[sectName] = append(conf.propNames[sectName], key)
+}
+
+// forget key in sect
+func (conf *Config) removePropName(sectName, key string) {
+	names := conf.propNames[sectName]
+	for i := range names {
+		if names[i] == key {
+			conf.propNames[sectName] = append(names[:i:i], names[i+1:]...)
+			return
+		}
+	}
+}
+
+// props of sect in the order they were first set, followed by any other props by name
+func (conf *Config) propOrder(sectName string) []string {
+	props := conf.sects[sectName]
+	names := make([]string, 0, len(props))
+	seen := make(map[string]struct{}, len(props))
+	for _, name := range conf.propNames[sectName] {
+		if _, exists := props[name]; exists {
+			names = append(names, name)
+			seen[name] = struct{}{}
+		}
+	}
+	if len(names) < len(props) {
+		for _, name := range sortedKeys(props) {
+			if _, done := seen[name]; !done {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func (conf *Config) addMacroPropsToSect(ctx *confContext.Context, macroName *string, origin *Origin, logger *Logger) error {
 
 	// Get current section
 	cs := ctx.RunTime.Params[confContext.CurrSect]
 	macro := conf.macros[*macroName]
 
-	// get config sectProps
-	sectProps, sectExists := conf.sects[cs]
-	if !sectExists {
-		sectProps = make(map[string]string)
+	// the sect exists, even if the macro has no props
+	if _, exists := conf.sects[cs]; !exists {
+		conf.sects[cs] = make(map[string]string)
 	}
-	for k, v := range macro.properties {
-		if v, err := conf.applyParamsAndConstants(v, &macro.parameters); err != nil {
-			return err
+	// the props are placed where the macro is used, in the order the macro defined them. Props already
+	// in the sect follow the duplicate policy, but all props are set before an error is returned
+	var dupErr error
+	for _, k := range macro.propOrder {
+		var items []string
+		var v string
+		var err error
+		if macro.lists[k] {
+			v, items, err = conf.applyParamsAndConstantsToList(ctx, macro.properties[k], &macro.parameters)
 		} else {
-			sectProps[k] = v
+			v, err = conf.applyParamsAndConstants(ctx, macro.properties[k], &macro.parameters)
+		}
+		if err != nil {
+			return err
+		}
+		if err := conf.setPropByPolicy(ctx, logger, cs, k, v, items, origin.withMacro(*macroName, macro.origins[k])); err != nil && dupErr == nil {
+			dupErr = err
 		}
 	}
-	conf.sects[cs] = sectProps
-	return nil
+	return dupErr
 }
 
-func (conf *Config) addPropsToMacro(ctx *confContext.Context, macroName *string) error {
+// add the props of a macro used within the macro being defined. origin is the [use] row, which is where the
+// props are defined in the current macro
+func (conf *Config) addPropsToMacro(ctx *confContext.Context, macroName *string, origin *Origin) error {
 
 	// Get current macro
 	cm := ctx.RunTime.Params[confContext.CurrMacro]
@@ -319,23 +520,53 @@ func (conf *Config) addPropsToMacro(ctx *confContext.Context, macroName *string)
 	useMacro := conf.macros[*macroName]
 
 	// add useMacro props to currMacro
-	for k, v := range useMacro.properties {
-		if v, err := conf.applyParamsAndConstants(v, &useMacro.parameters); err != nil {
+	for _, k := range useMacro.propOrder {
+		if v, err := conf.applyParamsAndConstants(ctx, useMacro.properties[k], &useMacro.parameters); err != nil {
 			return err
 		} else {
-			currMacro.properties[k] = v
+			currMacro.setProperty(k, v)
+			currMacro.setList(k, useMacro.lists[k])
+			currMacro.origins[k] = origin
 		}
 	}
 	return nil
 }
 
-func (conf *Config) applyParamsAndConstants(v string, params *map[string]string) (string, error) {
+func (conf *Config) applyParamsAndConstants(ctx *confContext.Context, v string, params *map[string]string) (string, error) {
 	// create new stringmask on v
 	sm := stringMask.NewStringMask(v, '-')
+	sm.MaskEscapes('\\', escapable, 'e', false)
+	if err := conf.maskParamsAndConstants(ctx, sm, params); err != nil {
+		return "", err
+	}
+	return sm.GetString('-', 'p', 'e'), nil
+}
+
+// like applyParamsAndConstants, for a list value like [a, {$p}]. Returns the value and the list items
+func (conf *Config) applyParamsAndConstantsToList(ctx *confContext.Context, v string, params *map[string]string) (string, []string, error) {
+	sm := stringMask.NewStringMask(v, '-')
+	sm.MaskEscapes('\\', escapable, 'e', false)
+	maskListBrackets(sm, -1)
+	if err := conf.maskParamsAndConstants(ctx, sm, params); err != nil {
+		return "", nil, err
+	}
+	items := make([]string, 0)
+	// an empty list has nothing but white space between the brackets
+	if strings.TrimSpace(v[1:len(v)-1]) != "" {
+		var err error
+		if items, err = splitListItems(sm, 'p'); err != nil {
+			return "", nil, err
+		}
+	}
+	return strings.Join(items, "\n"), items, nil
+}
+
+// mask params and constants in sm with 'p', and tag them with their values
+func (conf *Config) maskParamsAndConstants(ctx *confContext.Context, sm *stringMask.StringMask, params *map[string]string) error {
 	// begin with replacing all params with real values, ex {$p1} => "val1"
 	// first find all curly brackets
 	if cbs, cbe, err := sm.GetMaskPointsForOppositeRunes('{', '}', '-'); err != nil {
-		return "", err
+		return newRowError(KindMacro, -1, "%w", err)
 	} else {
 		// loop thru all curly brackets
 		for i, cb := range *cbs {
@@ -348,11 +579,14 @@ func (conf *Config) applyParamsAndConstants(v string, params *map[string]string)
 			}
 		}
 	}
-	// replace all constants
+	// replace all environment variables and constants
+	if err := conf.replaceEnvVars(ctx, sm, '-', 'p'); err != nil {
+		return err
+	}
 	if err := conf.replaceConstants(sm, '-', 'p'); err != nil {
-		return "", err
+		return err
 	}
-	return sm.GetString('-', 'p'), nil
+	return nil
 }
 
 func (conf *Config) getCurrentMacro(ctx *confContext.Context) *macro {
@@ -1,7 +1,21 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	config "github.com/grufgran/config/context"
 )
@@ -14,3 +28,1080 @@ func TestNewConfigFromFile(t *testing.T) {
 	conf, err := NewConfigFromFile(ctx, "testdata/test1.conf", nil)
 	t.Log(conf, err)
 }
+
+type decodeTLS struct {
+	Enabled bool `conf:"enabled"`
+}
+
+type decodeServer struct {
+	Host    string        `conf:"host"`
+	Port    int           `conf:"port"`
+	Debug   bool          `conf:"debug"`
+	Ratio   float64       `conf:"ratio"`
+	Timeout time.Duration `conf:"timeout"`
+	Aliases []string      `conf:"aliases"`
+	TLS     *decodeTLS    `conf:"server.tls"`
+}
+
+type decodeLimits struct {
+	Max uint `conf:"max"`
+	Min uint `conf:"min"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	conf, err := NewConfigFromFile(nil, "testdata/decode.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var target struct {
+		Server decodeServer `conf:"server"`
+		Limits decodeLimits `conf:"limits"`
+	}
+	err = conf.Unmarshal(&target)
+
+	// both props in limits are bad, tls.enabled is not a bool
+	var errs DecodeErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("expected 3 decode errors, got %v", err)
+	}
+	if errs[0].Row == 0 || !strings.HasSuffix(errs[0].FileName, "decode.conf") {
+		t.Errorf("expected error with position, got %v", errs[0])
+	}
+
+	s := target.Server
+	if s.Host != "localhost" || s.Port != 8080 || !s.Debug || s.Ratio != 0.75 || s.Timeout != 90*time.Second {
+		t.Errorf("unexpected server values: %+v", s)
+	}
+	if len(s.Aliases) != 2 || s.Aliases[1] != "example.com" {
+		t.Errorf("unexpected aliases: %v", s.Aliases)
+	}
+	if s.TLS == nil {
+		t.Errorf("nested struct not allocated")
+	}
+
+	// structs that unmarshal themselves from text are single props
+	conf, err = NewConfigFromString(nil, "[s]\nwhen = 2024-01-02T03:04:05Z\nip = 10.0.0.1\nips[] = 10.0.0.2\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scalars struct {
+		When    time.Time  `conf:"when"`
+		Pointer *time.Time `conf:"when"`
+		IP      net.IP     `conf:"ip"`
+		IPs     []net.IP   `conf:"ips"`
+	}
+	if err := conf.Sect("s").Decode(&scalars); err != nil {
+		t.Fatal(err)
+	}
+	if scalars.When.Hour() != 3 || scalars.Pointer == nil || !scalars.Pointer.Equal(scalars.When) {
+		t.Errorf("unexpected times: %v, %v", scalars.When, scalars.Pointer)
+	}
+	if scalars.IP.String() != "10.0.0.1" || len(scalars.IPs) != 1 || scalars.IPs[0].String() != "10.0.0.2" {
+		t.Errorf("unexpected ips: %v, %v", scalars.IP, scalars.IPs)
+	}
+}
+
+func TestPropAccessors(t *testing.T) {
+	if v, err := newProp("b", "yes", true).Bool(); err != nil || !v {
+		t.Errorf("Bool: %v, %v", v, err)
+	}
+	// ints are base 10, unless the base is asked for
+	for s, expected := range map[string]int{"010": 10, "08": 8, " 9 ": 9} {
+		if v, err := newProp("i", s, true).ValueOrDefault(0); err != nil || v != expected {
+			t.Errorf("ValueOrDefault(%q): %v, %v", s, v, err)
+		}
+	}
+	if v := newProp("i", "0x10", true).Int64OrDefault(0); v != 0 {
+		t.Errorf("Int64OrDefault: %v", v)
+	}
+	if v, err := newProp("i", "0x10", true).Int64Base(0); err != nil || v != 16 {
+		t.Errorf("Int64Base: %v, %v", v, err)
+	}
+	if v := newProp("u", "-1", true).UintOrDefault(7); v != 7 {
+		t.Errorf("UintOrDefault: %v", v)
+	}
+	if _, err := newProp("d", "", false).Duration(); err == nil {
+		t.Errorf("Duration on missing property should fail")
+	}
+	if v, err := newProp("t", "2024-01-02", true).Time("2006-01-02"); err != nil || v.Day() != 2 {
+		t.Errorf("Time: %v, %v", v, err)
+	}
+	if v, err := newProp("n", "10.0.0.0/8", true).CIDR(); err != nil || !v.Contains(newProp("ip", "10.1.2.3", true).IPOrDefault(nil)) {
+		t.Errorf("CIDR: %v, %v", v, err)
+	}
+	sizes := map[string]uint64{"512": 512, "10MB": 10000000, "1.5 KiB": 1536, "64k": 65536}
+	for s, expected := range sizes {
+		if v, err := newProp("s", s, true).ByteSize(); err != nil || v != expected {
+			t.Errorf("ByteSize(%v): %v, %v", s, v, err)
+		}
+	}
+	if v := newProp("l", "a, b,c", true).StringsOrDefault(nil); len(v) != 3 || v[2] != "c" {
+		t.Errorf("Strings: %v", v)
+	}
+}
+
+func TestPropOrigin(t *testing.T) {
+	basePaths := map[string]string{"site": "testdata/"}
+	ctx := config.NewContext(basePaths, "test2")
+	conf, err := NewConfigFromFile(ctx, "testdata/test1.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origin := conf.Sect("sectionrole").Prop("key2").Origin()
+	if origin == nil || !strings.HasSuffix(origin.FileName, "test1.conf") || origin.RowNumber != 44 {
+		t.Errorf("unexpected origin for key2: %v", origin)
+	}
+
+	// props from an included file know who included them
+	origin = conf.Sect("multiLine test").Prop("another_prop").Origin()
+	if origin == nil || !strings.HasSuffix(origin.FileName, "test2.conf") || len(origin.IncludedFrom) != 1 {
+		t.Errorf("unexpected origin for another_prop: %v", origin)
+	}
+
+	// props from macros point at the use row and the macro definition
+	origin = conf.Sect("usage1").Prop("y").Origin()
+	if origin == nil || origin.RowNumber != 16 || origin.Macro == nil || origin.Macro.Name != "myMacro" || origin.Macro.RowNumber != 11 {
+		t.Errorf("unexpected origin for y: %v", origin)
+	}
+	// props from a macro used within a macro point at the inner [use] row
+	origin = conf.Sect("usage3").Prop("c").Origin()
+	if origin == nil || origin.RowNumber != 36 || origin.Macro == nil || origin.Macro.Name != "myMacroInMacro" || origin.Macro.RowNumber != 31 {
+		t.Errorf("unexpected origin for c: %v", origin)
+	}
+	origin = conf.Sect("usage3").Prop("y2").Origin()
+	if origin == nil || origin.Macro == nil || origin.Macro.RowNumber != 32 {
+		t.Errorf("unexpected origin for y2: %v", origin)
+	}
+}
+
+func TestNewConfigFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/app.conf":           {Data: []byte("[app]\nname = demo\n[include = db.conf]\n[include_if_exists = missing.conf]\n")},
+		"conf/db.conf":            {Data: []byte("[db]\nhost = [app:name].local\n[include = ^/shared/common.conf]\n")},
+		"conf/shared/common.conf": {Data: []byte("[common]\nlevel = info\n")},
+	}
+	conf, err := NewConfigFromFS(nil, fsys, "conf/app.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := conf.PropVal("db", "host"); host != "demo.local" {
+		t.Errorf("unexpected host: %v", host)
+	}
+	if level, _ := conf.PropVal("common", "level"); level != "info" {
+		t.Errorf("unexpected level: %v", level)
+	}
+	if conf.ConfFileName() != "conf/app.conf" {
+		t.Errorf("unexpected conf file name: %v", conf.ConfFileName())
+	}
+
+	// the context is left reading from the OS file system, and its conf root is not used within fsys
+	ctx := config.NewContext(nil)
+	if err := ctx.SetConfRoot("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	confRoot, _ := ctx.GetConfRoot()
+	conf, err = NewConfigFromFS(ctx, fsys, "conf/app.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level, _ := conf.PropVal("common", "level"); level != "info" {
+		t.Errorf("unexpected level: %v", level)
+	}
+	if root, _ := ctx.GetConfRoot(); root != confRoot {
+		t.Errorf("conf root changed by NewConfigFromFS: %v", root)
+	}
+	if ctx.FS != nil {
+		t.Error("context changed by NewConfigFromFS")
+	}
+	if _, err := NewConfigFromFile(ctx, "testdata/decode.conf", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewConfigFromString(t *testing.T) {
+	conf, err := NewConfigFromString(nil, "[s]\nkey = value\n[include = testdata/decode.conf]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := conf.PropVal("server", "port"); val != "8080" {
+		t.Errorf("include from string not resolved, got %v", conf.sects)
+	}
+}
+
+func TestEnvInterpolation(t *testing.T) {
+	env := map[string]string{"HOST": "db.local", "PORT": "5432"}
+	ctx := config.NewContext(nil)
+	ctx.LookupEnv = func(name string) (string, bool) {
+		val, exists := env[name]
+		return val, exists
+	}
+	src := "[db]\nhost = ${ENV:HOST}\nname = ${NAME:-app}\nurl = pg://${HOST}:${PORT}/[db:name]\nuser = ${USER}\nprice = \\${HOST}\nport = ${ENV:PORT:-80}\nmode = ${ENV:MODE:-dev}\n"
+	conf, err := NewConfigFromString(ctx, src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"host": "db.local", "name": "app", "url": "pg://db.local:5432/app", "user": "", "price": "${HOST}", "port": "5432", "mode": "dev"}
+	for k, v := range expected {
+		if val, _ := conf.PropVal("db", k); val != v {
+			t.Errorf("%v: expected %q, got %q", k, v, val)
+		}
+	}
+
+	ctx = config.NewContext(nil)
+	ctx.LookupEnv = func(string) (string, bool) { return "", false }
+	ctx.StrictEnv = true
+	if _, err := NewConfigFromString(ctx, "[db]\nuser = ${USER}\n", nil); err == nil {
+		t.Error("expected error for unset variable in strict mode")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	ctx := config.NewContext(nil)
+	ctx.EnvOverridePrefix = "APP"
+	ctx.SetEnv(map[string]string{
+		"APP__DATABASE__HOST":   "db.prod",
+		"APP__WEB_SERVER__PORT": "9090",
+		"APP__CACHE__TTL":       "60s",
+		"OTHER__DATABASE__HOST": "ignored",
+		"APP__DATABASE":         "ignored",
+	})
+	conf, err := NewConfigFromString(ctx, "[database]\nhost = localhost\n[web-server]\nPort = 80\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[[2]string]string{{"database", "host"}: "db.prod", {"web-server", "Port"}: "9090", {"cache", "ttl"}: "60s"}
+	for k, v := range expected {
+		if val, _ := conf.PropVal(k[0], k[1]); val != v {
+			t.Errorf("%v: expected %q, got %q", k, v, val)
+		}
+	}
+	origin := conf.Sect("database").Prop("host").Origin()
+	if origin == nil || origin.EnvVar != "APP__DATABASE__HOST" {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+
+	// new props are added in the order of the environment variables
+	ctx.SetEnv(map[string]string{"APP__S__D": "4", "APP__S__B": "2", "APP__S__C": "3", "APP__S__A": "1", "APP__S__E": "5"})
+	conf, err = NewConfigFromString(ctx, "[s]\nz = 0\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := conf.Sect("s").PropNames(); strings.Join(names, ",") != "z,a,b,c,d,e" {
+		t.Errorf("unexpected prop order %v", names)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	ctx := config.NewContext(nil)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags := NewFlags(fs, ctx)
+	flags.Prop(fs, "server", "port", "port to listen on")
+	args := []string{"-claim", "prod,eu", "-basepath", "site=testdata", "-set", "server.tls.enabled=true", "-server.port", "443", "-set", "limits.max=1"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := ctx.Claims["eu"]; !exists {
+		t.Errorf("claims not added: %v", ctx.Claims)
+	}
+	if _, err := ctx.GetBasePath("site"); err != nil {
+		t.Error(err)
+	}
+
+	conf, err := NewConfigFromFile(ctx, "testdata/decode.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags.Apply(conf)
+	expected := map[[2]string]string{{"server", "port"}: "443", {"server.tls", "enabled"}: "true", {"limits", "max"}: "1"}
+	for k, v := range expected {
+		if val, _ := conf.PropVal(k[0], k[1]); val != v {
+			t.Errorf("%v: expected %q, got %q", k, v, val)
+		}
+	}
+	if origin := conf.Sect("server").Prop("port").Origin(); origin == nil || origin.Flag != "-server.port" {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+	if err := fs.Parse([]string{"-set", "novalue"}); err == nil {
+		t.Error("expected error for -set without =")
+	}
+
+	// schema props don't replace flags already registered
+	schema, err := LoadSchema(nil, "testdata/decode.schema", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags.SchemaProps(fs, schema)
+	flags.SchemaProps(fs, schema)
+	if fs.Lookup("server.port").Usage != "port to listen on" || fs.Lookup("server.host") == nil {
+		t.Error("unexpected schema flags")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	conf, err := NewConfigFromFile(nil, "testdata/decode.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadSchema(nil, "testdata/decode.schema", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs ValidationErrors
+	if !errors.As(conf.Validate(schema), &errs) {
+		t.Fatal("expected validation errors")
+	}
+	// ratio, timeout, enabled and missing logging
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 violations, got %v", errs)
+	}
+	if errs[0].Prop != "ratio" || errs[0].Row != 6 || !strings.HasSuffix(errs[0].FileName, "decode.conf") {
+		t.Errorf("unexpected violation: %v", errs[0])
+	}
+
+	// a typo in a property name is found
+	schema = NewSchema().AllowUnknownSects()
+	schema.Sect("server").Prop("hostname").Required()
+	schema.Sect("server").Prop("port").Type(TypeInt).Enum("80", "443")
+	if !errors.As(conf.Validate(schema), &errs) {
+		t.Fatal("expected validation errors")
+	}
+	expected := []struct{ prop, msg string }{
+		{"host", "unknown property"},
+		{"port", "8080 is not one of 80, 443"},
+		{"debug", "unknown property"},
+		{"ratio", "unknown property"},
+		{"timeout", "unknown property"},
+		{"aliases", "unknown property"},
+		{"hostname", "required property is missing"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %v violations, got %v", len(expected), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Sect != "server" || errs[i].Prop != e.prop || errs[i].Msg != e.msg {
+			t.Errorf("violation %v: expected [server] %v: %v, got %v", i, e.prop, e.msg, errs[i])
+		}
+	}
+
+	// schema files are parsed as conf files, so the regular expression escapes must be escaped too
+	schemaConf, err := NewConfigFromString(nil, "[s]\n"+`x = string pattern=^\#\\\[a\\\]$`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema, err = SchemaFromConfig(schemaConf); err != nil {
+		t.Fatal(err)
+	}
+	for value, valid := range map[string]bool{`\#\[a\]`: true, `a`: false} {
+		conf, err := NewConfigFromString(nil, "[s]\nx = "+value, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.Validate(schema); (err == nil) != valid {
+			t.Errorf("%v: unexpected result %v", value, err)
+		}
+	}
+
+	// the items of a list are counted, not the commas in them
+	schema = NewSchema()
+	schema.Sect("s").Prop("l").Type(TypeList).Max(1)
+	for text, valid := range map[string]bool{"l[] = a,b": true, "l = a,b": false, "l = [a, b]": false} {
+		conf, err := NewConfigFromString(nil, "[s]\n"+text, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.Validate(schema); (err == nil) != valid {
+			t.Errorf("%v: unexpected result %v", text, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	ctx := config.NewContext(nil)
+	ctx.SetEnv(nil)
+	ctx.StrictEnv = true
+
+	// stops at the first error
+	_, err := NewConfigFromFile(ctx.Copy(), "testdata/errors/main.conf", nil)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Kind != KindConstant || pe.Line != 3 || pe.Column != 5 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// lenient mode collects all errors
+	ctx.Lenient = true
+	_, err = NewConfigFromFile(ctx, "testdata/errors/main.conf", nil)
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %v", err)
+	}
+	kinds := []ErrorKind{KindConstant, KindSyntax, KindConstant, KindMacro, KindEnv}
+	for i, kind := range kinds {
+		if errs[i].Kind != kind {
+			t.Errorf("error %v: expected kind %v, got %v", i, kind, errs[i])
+		}
+	}
+	if bad := errs[2]; !strings.HasSuffix(bad.FileName, "bad.conf") || bad.Line != 2 || len(bad.IncludedFrom) != 1 {
+		t.Errorf("unexpected error from included file: %v", bad)
+	}
+
+	// errors in macro bodies have no column on the [use] row
+	_, err = NewConfigFromString(nil, "[define m($p)]\nx = {$p} [b:missing]\n[s]\n[use m(1)]\n", nil)
+	if !errors.As(err, &pe) || pe.Kind != KindConstant || pe.Line != 4 || pe.Column != 0 {
+		t.Errorf("unexpected macro error: %v", err)
+	}
+
+	// the rows of a sect with a bad header are skipped
+	lenient := config.NewContext(nil)
+	lenient.Lenient = true
+	conf, err := NewConfigFromString(lenient, "[a]\nx = 1\n[ broken\ny = 2\n[d]\nz = 3\n", nil)
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", err)
+	}
+	if _, exists := conf.PropVal("a", "y"); exists {
+		t.Error("row after a bad sect header ended up in the sect before")
+	}
+	if val, _ := conf.PropVal("d", "z"); val != "3" {
+		t.Errorf("rows after the next sect should be read, got %v", conf.GetSects())
+	}
+
+	// an error inside a multiline value does not end the value
+	multiLines := map[string]string{
+		"[s]\nk = <<EOF\na [x:y]\nb = 2\nEOF\nc = 3\n": "b = 2",
+		"[s]\nk = a \\\n[x:y] \\\nb = 2\nc = 3\n":      "a b = 2",
+	}
+	for text, expected := range multiLines {
+		conf, err := NewConfigFromString(lenient.Copy(), text, nil)
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Kind != KindConstant || errs[0].Line != 3 {
+			t.Errorf("%q: expected 1 error on row 3, got %v", text, err)
+		}
+		if val, _ := conf.PropVal("s", "k"); val != expected {
+			t.Errorf("%q: expected k = %q, got %q", text, expected, val)
+		}
+		if _, exists := conf.PropVal("s", "b"); exists {
+			t.Errorf("%q: a row of the multiline value was read as a property", text)
+		}
+		if val, _ := conf.PropVal("s", "c"); val != "3" {
+			t.Errorf("%q: rows after the multiline value should be read, got %v", text, conf.GetSects())
+		}
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	conf, err := NewConfigFromFile(nil, "testdata/errors/lint.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unused := conf.UnusedMacros(); len(unused) != 1 || unused[0].Name != "unused" || unused[0].Origin.RowNumber != 2 {
+		t.Errorf("unexpected unused macros: %v", unused)
+	}
+	if dups := conf.DuplicateProps(); len(dups) != 1 || dups[0].Origin.RowNumber != 7 || dups[0].Previous.RowNumber != 6 {
+		t.Errorf("unexpected duplicates: %v", dups)
+	}
+	if missing := conf.MissingIncludes(); len(missing) != 1 || !strings.HasSuffix(missing[0].FileName, "nothing.conf") {
+		t.Errorf("unexpected missing includes: %v", missing)
+	}
+	if skipped := conf.SkippedSects(); len(skipped) != 1 || skipped[0].Name != "b" || skipped[0].Claims != "prod" {
+		t.Errorf("unexpected skipped sects: %v", skipped)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	basePaths := map[string]string{"site": "testdata/"}
+	ctx := config.NewContext(basePaths, "test2")
+	conf, err := NewConfigFromFile(ctx, "testdata/test1.conf", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := NewSchema().AllowUnknownSects()
+	schema.Sect("")
+	conf.Validate(schema)
+	events := []string{
+		`msg="reading file"`,
+		`msg="entering sect" file=` + conf.ConfFileName() + ` line=15 section=usage1`,
+		`msg="defining macro" file=` + conf.ConfFileName() + ` line=8 macro=myMacro params="$y, $z"`,
+		`msg="using macro"`,
+		`msg="constant replaced" section="" key=testrole value=test`,
+		`msg="include resolved"`,
+		`msg="sect skipped"`,
+		`level=WARN msg="validation failed" file=` + conf.ConfFileName() + ` line=3 section="" key=testrole error="unknown property"`,
+	}
+	for _, event := range events {
+		if !strings.Contains(buf.String(), event) {
+			t.Errorf("missing trace event %q", event)
+		}
+	}
+
+	// loggers without attributes get them in the message
+	var legacy Logger = &testLogger{}
+	if _, err := NewConfigFromFile(config.NewContext(basePaths, "test2"), "testdata/test1.conf", &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(legacy.(*testLogger).String(), "entering sect file=") {
+		t.Errorf("missing attributes in legacy logger: %v", legacy)
+	}
+}
+
+type testLogger struct {
+	strings.Builder
+}
+
+func (l *testLogger) Debug(args ...any) {
+	fmt.Fprintln(l, args...)
+}
+
+func (l *testLogger) Debugf(format string, args ...any) {
+	fmt.Fprintf(l, format+"\n", args...)
+}
+
+func TestJSON(t *testing.T) {
+	conf, err := NewConfigFromFile(nil, "testdata/decode.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"server":{"host":"localhost","port":"8080","debug":"true"`) {
+		t.Errorf("unexpected json: %s", data)
+	}
+	var decoded Config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if diff := Diff(conf, &decoded); !diff.IsEmpty() || strings.Join(decoded.SectNames(), ",") != "server,server.tls,limits" {
+		t.Errorf("round trip failed: %+v, %v", diff, decoded.SectNames())
+	}
+
+	// lists stay lists, even with a single item or items with commas
+	conf, err = NewConfigFromString(nil, "[s]\nl[] = a, b\nm = [x, y]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = json.Marshal(conf); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.lists, conf.lists) {
+		t.Errorf("lists lost in json %s: %v", data, decoded.lists)
+	}
+
+	src := "{\n  \"db\": {\n    \"port\": 5432,\n    \"tls\": true,\n    \"hosts\": [\"a\", \"b\"],\n    \"user\": null\n  },\n  \"empty\": {}\n}"
+	conf, err = NewConfigFromJSON(strings.NewReader(src), "db.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts, _ := conf.Sect("db").Prop("hosts").Strings(); len(hosts) != 2 || conf.PropOrDefault("db", "port", "") != "5432" {
+		t.Errorf("unexpected values: %v", conf.sects)
+	}
+	if origin := conf.Sect("db").Prop("tls").Origin(); origin.FileName != "db.json" || origin.RowNumber != 4 {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+	_, err = NewConfigFromJSON(strings.NewReader("{\"db\": {\n\"x\": {}}}"), "bad.json")
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 {
+		t.Errorf("expected positioned error, got %v", err)
+	}
+	if _, err = NewConfigFromJSON(strings.NewReader("{\"a\": {}}\n garbage"), "bad.json"); err == nil {
+		t.Error("expected error for data after the closing }")
+	}
+	if _, err = NewConfigFromJSON(strings.NewReader("{\"a\": {}} {}"), "bad.json"); err == nil {
+		t.Error("expected error for a second object")
+	}
+	if _, err = NewConfigFromJSON(strings.NewReader("{\"a\": {}}\n\n"), "ok.json"); err != nil {
+		t.Errorf("trailing white space should be allowed: %v", err)
+	}
+}
+
+func TestYAMLAndTOML(t *testing.T) {
+	f, err := os.Open("testdata/formats.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	conf, err := NewConfigFromYAML(f, "formats.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[[2]string]string{
+		{"", "name"}: "demo", {"server", "host"}: "db.local", {"server", "port"}: "8080", {"server", "tags"}: "a\nb c\nd,e",
+		{"server", "aliases"}: "www.example.com\nexample.com", {"server", "motd"}: "hello\n  world\n",
+		{"server", "folded"}: "one two\nthree", {"server.tls", "enabled"}: "true", {"", "empty"}: "",
+	}
+	for k, v := range expected {
+		if val, exists := conf.PropVal(k[0], k[1]); val != v || !exists {
+			t.Errorf("yaml %v: expected %q, got %q", k, v, val)
+		}
+	}
+	if origin := conf.Sect("server").Prop("port").Origin(); origin.RowNumber != 5 {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+
+	conf, err = NewConfigFromFile(nil, "testdata/formats.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = map[[2]string]string{
+		{"", "name"}: "demo", {"server", "host"}: "db.local", {"server", "port"}: "8080", {"server", "tags"}: "a\nb c\nd,e",
+		{"server", "motd"}: "hello\n  world", {"server.tls", "enabled"}: "true", {"server.limits", "max"}: "10",
+		{"server", "when"}: "1979-05-27 07:32:00", {"base", "y"}: "8080", {"server", "mask"}: "31",
+		{"server", "mode"}: "493", {"server", "flags"}: "5", {"server", "ratio"}: "1000.5", {"server", "zip"}: "01234",
+	}
+	for k, v := range expected {
+		if val, exists := conf.PropVal(k[0], k[1]); val != v || !exists {
+			t.Errorf("toml %v: expected %q, got %q", k, v, val)
+		}
+	}
+	if port, err := conf.Sect("server").Prop("port").Int(); err != nil || port != 8080 {
+		t.Errorf("port: %v, %v", port, err)
+	}
+	if origin := conf.Sect("server.tls").Prop("enabled").Origin(); origin.RowNumber != 12 || len(origin.IncludedFrom) != 1 {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+
+	_, err = NewConfigFromTOML(strings.NewReader("[a]\nx = 1\ny = \"open\n"), "bad.toml")
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 3 {
+		t.Errorf("expected positioned error, got %v", err)
+	}
+
+	// strings must be quoted
+	for _, value := range []string{"hello world", "hello", "Infinity", "1979-13-27", "True"} {
+		if _, err := NewConfigFromTOML(strings.NewReader("x = "+value+"\n"), "bad.toml"); err == nil || !strings.Contains(err.Error(), "must be quoted") {
+			t.Errorf("%v: expected unquoted string error, got %v", value, err)
+		}
+	}
+	for _, value := range []string{"-inf", "nan", "1e6", "07:32:00", "1979-05-27T07:32:00z", "1979-05-27T00:32:00.999999-07:00"} {
+		if _, err := NewConfigFromTOML(strings.NewReader("x = "+value+"\n"), "ok.toml"); err != nil {
+			t.Errorf("%v: %v", value, err)
+		}
+	}
+
+	// arrays and lists are list props, so items may contain newlines
+	conf, err = NewConfigFromTOML(strings.NewReader("a = [\"x\\ny\", \"z\"]\nb = []\n"), "list.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := conf.Sect("").Prop("a").Strings(); len(items) != 2 || items[0] != "x\ny" {
+		t.Errorf("unexpected toml items %q", items)
+	}
+	if items, err := conf.Sect("").Prop("b").Strings(); err != nil || len(items) != 0 {
+		t.Errorf("unexpected empty toml array %q, %v", items, err)
+	}
+	conf, err = NewConfigFromYAML(strings.NewReader("s:\n  a:\n    - \"x, y\"\n  b: [\"p\\nq\", r]\n"), "list.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := conf.Sect("s").Prop("a").Strings(); len(items) != 1 || items[0] != "x, y" {
+		t.Errorf("unexpected yaml items %q", items)
+	}
+	if items, _ := conf.Sect("s").Prop("b").Strings(); len(items) != 2 || items[0] != "p\nq" {
+		t.Errorf("unexpected yaml flow items %q", items)
+	}
+}
+
+func TestPropertiesAndDotenv(t *testing.T) {
+	conf, err := NewConfigFromFile(nil, "testdata/keyvalue.conf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[[2]string]string{
+		{"db", "DB_USER"}: "admin", {"db", "DB_PASS"}: "s3cr$t#1", {"db", "GREETING"}: "hello\n\"world\"",
+		{"db", "CERT"}: "line one\nline two", {"db", "EMPTY"}: "", {"db", "PLAIN"}: "some value",
+		{"app", "port"}: "8080", {"app", "name"}: "demo app", {"app", "path"}: `c:\temp\app`,
+		{"app", "list"}: "one, two, three", {"app", "unicode"}: "café", {"app", "key with spaces"}: "yes",
+		{"db", "url"}: "localhost:8080",
+	}
+	for k, v := range expected {
+		if val, exists := conf.PropVal(k[0], k[1]); val != v || !exists {
+			t.Errorf("%v: expected %q, got %q", k, v, val)
+		}
+	}
+	if origin := conf.Sect("app").Prop("unicode").Origin(); origin.RowNumber != 9 || len(origin.IncludedFrom) != 1 {
+		t.Errorf("unexpected origin: %v", origin)
+	}
+
+	_, err = NewConfigFromReader(nil, strings.NewReader("[a]\n[include = formats.yaml > b]\n"), "testdata/main.conf", nil)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Kind != KindInclude {
+		t.Errorf("expected include error, got %v", err)
+	}
+	_, err = NewConfigFromDotenv(strings.NewReader("A=1\nB=\"open\n"), "bad.env", "")
+	if !errors.As(err, &pe) || pe.Line != 2 {
+		t.Errorf("expected positioned error, got %v", err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	defaults, err := NewConfigFromString(nil, "[server]\nhost = localhost\ntimeout = 5s\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := config.NewContext(nil)
+	ctx.SetEnv(map[string]string{"APP__SERVER__TIMEOUT": "10s"})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := NewFlags(fs, ctx)
+	if err := fs.Parse([]string{"-set", "log.level=warn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewLoader(ctx, nil).
+		Layer("defaults", defaults).
+		File("system", "testdata/layers/system.conf").
+		OptionalFile("user", "testdata/layers/user.conf").
+		OptionalFile("missing", "testdata/layers/missing.conf").
+		Env("env", "APP").
+		Flags("flags", flags)
+	conf, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[[3]string]string{
+		{"server", "host", "system"}: "system.local", {"server", "port", "user"}: "8080",
+		{"server", "timeout", "env"}: "10s", {"log", "level", "flags"}: "warn",
+	}
+	for k, v := range expected {
+		prop := conf.Sect(k[0]).Prop(k[1])
+		if val, _ := prop.Value(); val != v || prop.Origin() == nil || prop.Origin().Layer != k[2] {
+			t.Errorf("%v: expected %q, got %q from %v", k, v, val, prop.Origin())
+		}
+	}
+	if len(conf.filesMissing) != 1 {
+		t.Errorf("expected missing file to be remembered: %v", conf.filesMissing)
+	}
+
+	_, err = loader.Policy("server", "", ErrorOnConflict).Load()
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Prop != "host" || conflict.Layer != "system" || conflict.PreviousLayer != "defaults" {
+		t.Errorf("expected conflict, got %v", err)
+	}
+
+	merged := Merge(defaults, nil, conf)
+	if val, _ := merged.PropVal("server", "timeout"); val != "10s" {
+		t.Errorf("unexpected merged value %q", val)
+	}
+
+	// an optional layer with a broken include is not skipped
+	broken := filepath.Join(t.TempDir(), "broken.conf")
+	if err := os.WriteFile(broken, []byte("[s]\n[include = missing.conf]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLoader(nil, nil).OptionalFile("broken", broken).Load(); err == nil {
+		t.Error("expected error from the include of an optional layer")
+	}
+}
+
+func TestMutation(t *testing.T) {
+	conf := NewConfig()
+	if err := conf.SetProp("server", "host", "localhost"); err != nil {
+		t.Fatal(err)
+	}
+	conf.SetProp("log", "level", "info")
+	conf.Sect("db").SetProp("url", "postgres://db")
+	if strings.Join(conf.SectNames(), ",") != "server,log,db" {
+		t.Errorf("unexpected sect order: %v", conf.SectNames())
+	}
+	if err := conf.RenameSect("log", "logging"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.RenameSect("server", "db"); err == nil {
+		t.Error("expected error renaming to existing sect")
+	}
+	if err := conf.Sect("db").Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.DeleteProp("server", "host"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.DeleteProp("server", "host"); err == nil {
+		t.Error("expected error deleting missing prop")
+	}
+	if strings.Join(conf.SectNames(), ",") != "server,logging" || len(conf.GetSects()["server"]) != 0 {
+		t.Errorf("unexpected config: %v %v", conf.SectNames(), conf.GetSects())
+	}
+	if level, _ := conf.PropVal("logging", "level"); level != "info" {
+		t.Errorf("props not renamed with sect: %v", conf.GetSects())
+	}
+
+	schema := NewSchema()
+	schema.Sect("server").Prop("port").Type(TypeInt).Max(65535).Required()
+	schema.Sect("logging").Required().AllowUnknown()
+	if err := conf.SetSchema(schema); err == nil {
+		t.Error("expected error setting schema on invalid config")
+	}
+	conf.SetProp("server", "port", "80")
+	if err := conf.SetSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	var ve *ValidationError
+	if err := conf.SetProp("server", "port", "99999"); !errors.As(err, &ve) || ve.Prop != "port" {
+		t.Errorf("expected validation error, got %v", err)
+	}
+	if err := conf.SetProp("other", "key", "value"); !errors.As(err, &ve) {
+		t.Errorf("expected unknown sect error, got %v", err)
+	}
+	if err := conf.DeleteProp("server", "port"); !errors.As(err, &ve) {
+		t.Errorf("expected required prop error, got %v", err)
+	}
+	if err := conf.DeleteSect("logging"); !errors.As(err, &ve) {
+		t.Errorf("expected required sect error, got %v", err)
+	}
+	if err := conf.Snapshot().Sect("server").SetProp("port", "81"); err == nil {
+		t.Error("expected snapshot sect to be read only")
+	}
+}
+
+func TestPropOrder(t *testing.T) {
+	conf, err := NewConfigFromString(nil, "[s]\nzeta = 1\n[define m($v)]\nm2 = {$v}\nm1 = x\n[s]\nalpha = 2\n[use m(3)]\nbeta = 4\nzeta = 5\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sect := conf.Sect("s")
+	if names := strings.Join(sect.PropNames(), ","); names != "zeta,alpha,m2,m1,beta" {
+		t.Errorf("unexpected order: %v", names)
+	}
+	conf.DeleteProp("s", "alpha")
+	conf.SetProp("s", "alpha", "6")
+	visited := make([]string, 0)
+	sect.Range(func(prop *Prop) bool {
+		visited = append(visited, prop.Name())
+		return prop.Name() != "beta"
+	})
+	if names := strings.Join(visited, ","); names != "zeta,m2,m1,beta" {
+		t.Errorf("unexpected range: %v", names)
+	}
+
+	var sb strings.Builder
+	if _, err := conf.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "[s]\nzeta = 5\nm2 = 3\nm1 = x\nbeta = 4\nalpha = 6\n" {
+		t.Errorf("unexpected output:\n%v", sb.String())
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	text := "[s]\nallow = a\nx = 1\nallow = b\nallow = <<END\nc\nd\nEND\ny = 2\n"
+	parseText := func(text string, policy config.DuplicatePolicy, lenient bool, logger *Logger) (*Config, error) {
+		ctx := config.NewContext(nil)
+		ctx.Duplicates = policy
+		ctx.Lenient = lenient
+		return NewConfigFromString(ctx, text, logger)
+	}
+	parse := func(policy config.DuplicatePolicy, lenient bool, logger *Logger) (*Config, error) {
+		return parseText(text, policy, lenient, logger)
+	}
+
+	conf, err := parse(config.DuplicateAccumulate, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := conf.Sect("s").Prop("allow").Values()
+	if err != nil || strings.Join(values, "|") != "a|b|c\nd" {
+		t.Errorf("unexpected values %q, %v", values, err)
+	}
+	var sb strings.Builder
+	if _, err := conf.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if reread, err := parseText(sb.String(), config.DuplicateAccumulate, false, nil); err != nil || !Diff(conf, reread).IsEmpty() {
+		t.Errorf("round trip failed: %v\n%v", err, sb.String())
+	} else if again, _ := reread.Sect("s").Prop("allow").Values(); strings.Join(again, "|") != "a|b|c\nd" {
+		t.Errorf("values lost when writing: %q\n%v", again, sb.String())
+	}
+
+	conf, err = parse(config.DuplicateFirstWins, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := conf.PropVal("s", "allow"); val != "a" || len(conf.GetSects()["s"]) != 3 {
+		t.Errorf("first value should win: %v", conf.GetSects())
+	}
+	if values, _ := conf.Sect("s").Prop("allow").Values(); len(values) != 1 {
+		t.Errorf("unexpected values %q", values)
+	}
+
+	_, err = parse(config.DuplicateError, false, nil)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Kind != KindDuplicate || pe.Line != 4 {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+	conf, err = parse(config.DuplicateError, true, nil)
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("expected two duplicate errors, got %v", err)
+	}
+	if val, _ := conf.PropVal("s", "y"); val != "2" {
+		t.Errorf("rows after a skipped multiline value not parsed: %v", conf.GetSects())
+	}
+
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	if conf, err = parse(config.DuplicateWarn, false, logger); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := conf.PropVal("s", "allow"); val != "c\nd" || !strings.Contains(buf.String(), `level=WARN msg="duplicate property"`) {
+		t.Errorf("expected warning and last value, got %q:\n%v", val, buf.String())
+	}
+
+	// props from macros and included files of other formats follow the policy too
+	fsys := fstest.MapFS{
+		"conf/main.conf": {Data: []byte("[define m($v)]\nallow = {$v}\n[s]\nallow = a\n[use m(b)]\n[include = x.env]\n")},
+		"conf/x.env":     {Data: []byte("allow=c\n")},
+	}
+	parseFS := func(policy config.DuplicatePolicy) (*Config, error) {
+		ctx := config.NewContext(nil)
+		ctx.Duplicates = policy
+		return NewConfigFromFS(ctx, fsys, "conf/main.conf", nil)
+	}
+	if conf, err = parseFS(config.DuplicateAccumulate); err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := conf.Sect("s").Prop("allow").Values(); strings.Join(values, "|") != "a|b|c" || len(conf.DuplicateProps()) != 2 {
+		t.Errorf("unexpected values %q", values)
+	}
+	if conf, err = parseFS(config.DuplicateFirstWins); err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := conf.PropVal("s", "allow"); val != "a" || conf.Sect("s").Prop("allow").Origin().RowNumber != 4 {
+		t.Errorf("first value should win: %q", val)
+	}
+	_, err = parseFS(config.DuplicateError)
+	if !errors.As(err, &pe) || pe.Kind != KindDuplicate || pe.Line != 5 {
+		t.Errorf("expected duplicate error from macro, got %v", err)
+	}
+	fsys["conf/main.conf"] = &fstest.MapFile{Data: []byte("[s]\nallow = a\n[include = x.env]\n")}
+	_, err = parseFS(config.DuplicateError)
+	if !errors.As(err, &pe) || pe.Kind != KindDuplicate || !strings.HasSuffix(pe.FileName, "x.env") || pe.Line != 1 {
+		t.Errorf("expected duplicate error from include, got %v", err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	text := "[db]\nport = 5432\n[s]\nhosts[] = a\nhosts[] = b\ntags = [x, \"y, z\", \"\", [db:port] ]\nports = [80, 443]\nempty = []\ntext = \\[a\\]\nconst = [db:port]\nhosts[] = c\n"
+	conf, err := NewConfigFromString(nil, text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sect := conf.Sect("s")
+	check := func(name, expected string) {
+		t.Helper()
+		items, err := sect.Prop(name).Strings()
+		if err != nil || strings.Join(items, "|") != expected {
+			t.Errorf("%v: expected %q, got %q, %v", name, expected, strings.Join(items, "|"), err)
+		}
+	}
+	check("hosts", "a|b|c")
+	check("tags", "x|y, z||5432")
+	check("empty", "")
+	if ports, err := sect.Prop("ports").Ints(); err != nil || len(ports) != 2 || ports[1] != 443 {
+		t.Errorf("unexpected ports %v, %v", ports, err)
+	}
+	if _, err := sect.Prop("tags").Ints(); err == nil {
+		t.Error("expected error for items that are not ints")
+	}
+	if val, _ := conf.PropVal("s", "text"); val != "[a]" {
+		t.Errorf("escaped brackets should be text, got %q", val)
+	}
+	if val, _ := conf.PropVal("s", "const"); val != "5432" || sect.Prop("const").items != nil {
+		t.Errorf("constant should not be a list, got %q", val)
+	}
+	// brackets without a comma are not a list
+	literal, err := NewConfigFromString(nil, "[s]\ntitle = [DRAFT]\nre = [0-9]\none = [ a ]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{"title": "[DRAFT]", "re": "[0-9]", "one": "[ a ]"} {
+		if val, _ := literal.PropVal("s", name); val != expected || literal.Sect("s").Prop(name).items != nil {
+			t.Errorf("%v: expected %q, got %q", name, expected, val)
+		}
+	}
+
+	var sb strings.Builder
+	if _, err := conf.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := NewConfigFromString(nil, sb.String(), nil)
+	if err != nil || !Diff(conf, reread).IsEmpty() {
+		t.Fatalf("round trip failed: %v\n%v", err, sb.String())
+	}
+	sect = reread.Sect("s")
+	check("tags", "x|y, z||5432")
+	check("empty", "")
+	if b, err := json.Marshal(reread); err != nil || !strings.Contains(string(b), `"hosts":["a","b","c"]`) {
+		t.Errorf("lists should be json arrays: %s, %v", b, err)
+	}
+
+	// items that look like constants, or a single item
+	special, err := NewConfigFromString(nil, "[s]\none[] = a:b\ntwo = [\"a:b\", \"\\[c\\]\"]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Reset()
+	if _, err := special.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := NewConfigFromString(nil, sb.String(), nil); err != nil || !Diff(special, again).IsEmpty() {
+		t.Fatalf("round trip failed: %v\n%v", err, sb.String())
+	} else {
+		sect = again.Sect("s")
+	}
+	check("one", "a:b")
+	check("two", "a:b|[c]")
+
+	// lists in macros are split when the macro is used
+	macros, err := NewConfigFromString(nil, "[db]\nport = 5432\n[define m($a)]\nk = [x, {$a}, \"[db:port]\"]\nnone = []\ntext = [{$a}]\n[s]\n[use m(y)]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sect = macros.Sect("s")
+	check("k", "x|y|5432")
+	check("none", "")
+	if val, _ := macros.PropVal("s", "text"); val != "[y]" {
+		t.Errorf("brackets without a comma should be text, got %q", val)
+	}
+	if _, err := NewConfigFromString(nil, "[define m]\nk[] = x\n", nil); err == nil {
+		t.Error("expected error for key[] rows in macros")
+	}
+
+	var target struct {
+		Tags  []string `conf:"tags"`
+		Ports []int    `conf:"ports"`
+	}
+	if err := reread.Sect("s").Decode(&target); err != nil || len(target.Tags) != 4 || target.Ports[1] != 443 {
+		t.Errorf("unexpected decode %+v, %v", target, err)
+	}
+}
+
+// a backslash before an escapable rune is removed when reading, other backslashes are kept
+func TestEscapes(t *testing.T) {
+	conf, err := NewConfigFromString(nil, `[s]
+backslash = c:\\dir
+comment = a \# b # comment
+brackets = \[a:b\]
+constant = \${HOME}
+other = c:\temp
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"backslash": `c:\dir`,
+		"comment":   `a # b`,
+		"brackets":  `[a:b]`,
+		"constant":  `${HOME}`,
+		"other":     `c:\temp`,
+	}
+	for key, value := range expected {
+		if v, _ := conf.Sect("s").Prop(key).Value(); v != value {
+			t.Errorf("%v: expected %q, got %q", key, value, v)
+		}
+	}
+}
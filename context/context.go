@@ -3,6 +3,7 @@ package context
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,8 +14,40 @@ type Context struct {
 	Claims    claimSet
 	Stack     stack
 	RunTime   runTimeValues
+	// file system to read from. Nil means the OS file system
+	FS fs.FS
+	// lookup for ${NAME} in values. Nil means os.LookupEnv
+	LookupEnv func(string) (string, bool)
+	// fail on unset environment variables without default
+	StrictEnv bool
+	// collect all parse errors and keep parsing, instead of stopping at the first error
+	Lenient bool
+	// list of environment variables as KEY=value. Nil means os.Environ
+	Environ func() []string
+	// if set, environment variables like <prefix><separator>SECT<separator>PROP overrides parsed properties
+	EnvOverridePrefix string
+	// separator for environment overrides. Empty means "__"
+	EnvOverrideSeparator string
+	// what happens when a property is set twice in the same sect
+	Duplicates DuplicatePolicy
 }
 
+// DuplicatePolicy tells what happens when a property is set on more than one row in the same sect
+type DuplicatePolicy int8
+
+const (
+	// the last value wins
+	DuplicateLastWins DuplicatePolicy = iota
+	// the first value wins
+	DuplicateFirstWins
+	// the last value wins, and a warning is logged
+	DuplicateWarn
+	// parsing fails, or the row is skipped in lenient mode
+	DuplicateError
+	// all values are kept, in order. The last value is the value of the property
+	DuplicateAccumulate
+)
+
 // New ConfContext.
 func NewContext(basePaths map[string]string, claims ...string) *Context {
 	if basePaths == nil {
@@ -32,6 +65,28 @@ func NewContext(basePaths map[string]string, claims ...string) *Context {
 	return &ctx
 }
 
+// Copy basePaths and claims into a new ConfContext, ready for parsing another file
+func (ctx *Context) Copy() *Context {
+	basePaths := make(map[string]string, len(ctx.BasePaths))
+	for k, v := range ctx.BasePaths {
+		basePaths[k] = v
+	}
+	claims := make([]string, 0, len(ctx.Claims))
+	for claim := range ctx.Claims {
+		claims = append(claims, claim)
+	}
+	copy := NewContext(basePaths, claims...)
+	copy.FS = ctx.FS
+	copy.LookupEnv = ctx.LookupEnv
+	copy.StrictEnv = ctx.StrictEnv
+	copy.Lenient = ctx.Lenient
+	copy.Environ = ctx.Environ
+	copy.EnvOverridePrefix = ctx.EnvOverridePrefix
+	copy.EnvOverrideSeparator = ctx.EnvOverrideSeparator
+	copy.Duplicates = ctx.Duplicates
+	return copy
+}
+
 // Add claims
 func (ctx *Context) AddClaims(claims ...string) {
 	for _, v := range claims {
@@ -47,15 +102,11 @@ func (ctx *Context) AddBasePaths(paths ...string) error {
 		items := strings.SplitN(p, "=", 2)
 		key := strings.TrimSpace(items[0])
 		path := strings.TrimSpace(items[1])
-		endsWithPathSeparator := strings.HasSuffix(path, string(os.PathSeparator))
 		// convert path separators to slash. Nothing is changed on linux or mac. But windows paths are changed
-		path = filepath.ToSlash(items[1])
-		// make sure paths always ends with a slash
-		if !endsWithPathSeparator {
-			path = path + "/"
-		}
-		absPath, err := filepath.Abs(path)
-		ctx.BasePaths[key] = absPath
+		path = filepath.ToSlash(path)
+		absPath, err := ctx.AbsPath(path)
+		// make sure paths always ends with a separator
+		ctx.BasePaths[key] = absPath + ctx.Separator()
 		if err != nil {
 			if sb.Len() > 0 {
 				sb.WriteRune('\n')
@@ -108,9 +159,9 @@ func (ctx *Context) GetConfRoot() (string, error) {
 func (ctx *Context) SetConfRoot(confRoot string) error {
 
 	// check if confRoot is a file, then strip the filename
-	if i, err := os.Stat(confRoot); err == nil {
+	if i, err := ctx.Stat(confRoot); err == nil {
 		if !i.IsDir() {
-			confRoot = filepath.Dir(confRoot)
+			confRoot = ctx.Dir(confRoot)
 		}
 	} else {
 		return fmt.Errorf("confRoot=%v, not found", confRoot)
@@ -118,7 +169,7 @@ func (ctx *Context) SetConfRoot(confRoot string) error {
 
 	// convert path separators to slash. Nothing is changed on linux or mac. But windows paths are changed
 	path := filepath.ToSlash(confRoot)
-	if absPath, err := filepath.Abs(path); err == nil {
+	if absPath, err := ctx.AbsPath(path); err == nil {
 		// make sure paths always ends with a slash
 		ctx.BasePaths["^/"] = absPath + "/"
 	} else {
@@ -149,13 +200,13 @@ func (ctx *Context) CheckIfFileExists(fileDir, fileName, basePath *string) (bool
 		// use fileDir
 		sb.Grow(len(*fileDir) + len(*fileName) + 1)
 		sb.WriteString(*fileDir)
-		sb.WriteRune(os.PathSeparator)
+		sb.WriteString(ctx.Separator())
 		sb.WriteString(*fileName)
 	}
-	if filePath, err := filepath.Abs(sb.String()); err != nil {
+	if filePath, err := ctx.AbsPath(sb.String()); err != nil {
 		return false, fileName, err
 	} else {
-		if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		if _, err := ctx.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
 			return false, &filePath, nil
 		} else {
 			// file exists, return abs path
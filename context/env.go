@@ -0,0 +1,70 @@
+package context
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Lookup an environment variable, using ctx.LookupEnv if set
+func (ctx *Context) Getenv(name string) (string, bool) {
+	if ctx.LookupEnv != nil {
+		return ctx.LookupEnv(name)
+	}
+	return os.LookupEnv(name)
+}
+
+// Use env instead of the process environment. Handy in tests
+func (ctx *Context) SetEnv(env map[string]string) {
+	ctx.LookupEnv = func(name string) (string, bool) {
+		val, exists := env[name]
+		return val, exists
+	}
+	ctx.Environ = func() []string {
+		vars := make([]string, 0, len(env))
+		for k, v := range env {
+			vars = append(vars, k+"="+v)
+		}
+		return vars
+	}
+}
+
+// List environment variables as KEY=value, using ctx.Environ if set
+func (ctx *Context) GetEnviron() []string {
+	if ctx.Environ != nil {
+		return ctx.Environ()
+	}
+	return os.Environ()
+}
+
+// Separator between prefix, sect and prop in environment overrides
+func (ctx *Context) GetEnvOverrideSeparator() string {
+	if ctx.EnvOverrideSeparator == "" {
+		return "__"
+	}
+	return ctx.EnvOverrideSeparator
+}
+
+// Expand the expression within ${...}. Supported forms are NAME, ENV:NAME, NAME:-default and ENV:NAME:-default.
+// Unset variables without default expands to an empty string, or an error if ctx.StrictEnv is set
+func (ctx *Context) ExpandEnv(expr string) (string, error) {
+	name := strings.TrimPrefix(expr, "ENV:")
+	def, hasDefault := "", false
+	if i := strings.Index(name, ":-"); i != -1 {
+		name, def, hasDefault = name[:i], name[i+2:], true
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("missing environment variable name in ${%v}", expr)
+	}
+	if val, exists := ctx.Getenv(name); exists {
+		return val, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	if ctx.StrictEnv {
+		return "", fmt.Errorf("environment variable %v is not set", name)
+	}
+	return "", nil
+}
@@ -0,0 +1,57 @@
+package context
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Use fsys instead of the OS file system. File names are then slash separated paths within fsys.
+// Set this before adding base paths or conf root
+func (ctx *Context) SetFS(fsys fs.FS) {
+	ctx.FS = fsys
+}
+
+// Open file for reading
+func (ctx *Context) Open(fileName string) (io.ReadCloser, error) {
+	if ctx.FS != nil {
+		return ctx.FS.Open(fileName)
+	}
+	return os.Open(fileName)
+}
+
+// Stat file
+func (ctx *Context) Stat(fileName string) (fs.FileInfo, error) {
+	if ctx.FS != nil {
+		return fs.Stat(ctx.FS, fileName)
+	}
+	return os.Stat(fileName)
+}
+
+// Absolute path for fileName. Within a fs.FS, this is the cleaned path from the root of the FS
+func (ctx *Context) AbsPath(fileName string) (string, error) {
+	if ctx.FS != nil {
+		p := path.Clean(strings.TrimPrefix(filepath.ToSlash(fileName), "/"))
+		return p, nil
+	}
+	return filepath.Abs(fileName)
+}
+
+// Directory of fileName
+func (ctx *Context) Dir(fileName string) string {
+	if ctx.FS != nil {
+		return path.Dir(fileName)
+	}
+	return filepath.Dir(fileName)
+}
+
+// Path separator used in file names
+func (ctx *Context) Separator() string {
+	if ctx.FS != nil {
+		return "/"
+	}
+	return string(os.PathSeparator)
+}
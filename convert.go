@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// parse bool. Besides what strconv.ParseBool accepts, yes/no and on/off are allowed
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(strings.TrimSpace(s))
+}
+
+// parse int in base 10. A leading 0 does not mean octal
+func parseInt(s string, bitSize int) (int64, error) {
+	return parseIntBase(s, 10, bitSize)
+}
+
+func parseIntBase(s string, base, bitSize int) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(s), base, bitSize)
+}
+
+func parseUint(s string, bitSize int) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(s), 10, bitSize)
+}
+
+func parseFloat(s string, bitSize int) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), bitSize)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	return time.ParseDuration(strings.TrimSpace(s))
+}
+
+func parseTime(s string, layout string) (time.Time, error) {
+	return time.Parse(layout, strings.TrimSpace(s))
+}
+
+func parseURL(s string) (*url.URL, error) {
+	return url.Parse(strings.TrimSpace(s))
+}
+
+func parseIP(s string) (net.IP, error) {
+	if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("invalid IP address: %v", s)
+}
+
+func parseCIDR(s string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(strings.TrimSpace(s))
+	return ipNet, err
+}
+
+// multipliers for byte sizes. K, M, G, T and the IEC units are powers of 1024, KB, MB, GB and TB are powers of 1000
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"tib": 1 << 40,
+}
+
+// parse byte sizes like "512", "10MB", "1.5 GiB" or "64k"
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+
+	// split number and unit
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if i == -1 {
+		i = len(s)
+	}
+	number := s[:i]
+	unit := strings.ToLower(strings.TrimSpace(s[i:]))
+
+	multiplier, exists := byteUnits[unit]
+	if !exists || number == "" {
+		return 0, fmt.Errorf("invalid byte size: %v", s)
+	}
+	if n, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if n > math.MaxUint64/multiplier {
+			return 0, fmt.Errorf("byte size out of range: %v", s)
+		}
+		return n * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size: %v", s)
+	}
+	size := f * float64(multiplier)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("byte size out of range: %v", s)
+	}
+	return uint64(size), nil
+}
+
+// split a multiline value on \n. A value on a single row is split on commas
+func splitList(val string) []string {
+	sep := ","
+	if strings.Contains(val, "\n") {
+		sep = "\n"
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(val, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
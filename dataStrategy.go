@@ -1,7 +1,9 @@
 package config
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
+	"strings"
 
 	confContext "github.com/grufgran/config/context"
 )
@@ -29,6 +31,15 @@ func newSkipSectionStrategy() *skipSectionStrategy {
 // handle skip section
 func (s *skipSectionStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
 	um.setSkipSectionMode(startSkipping)
+	// remember sects skipped because of claims
+	if data := um.getFileRowData(); data.findings[sectClaims] != "" {
+		logEvent(logger, slog.LevelDebug, "sect skipped", rowAttrs(data, slog.String("section", data.findings[skippedSectName]), slog.String("claim", data.findings[sectClaims]))...)
+		conf.report.skippedSects = append(conf.report.skippedSects, SkippedSect{
+			Name:   data.findings[skippedSectName],
+			Claims: data.findings[sectClaims],
+			Origin: newOrigin(ctx, data),
+		})
+	}
 	return nil
 }
 
@@ -47,6 +58,8 @@ func newSectStrategy(currSect string) *sectStrategy {
 func (s *sectStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
 	ctx.RunTime.SetCurrentSect(s.currSect)
 	um.setSkipSectionMode(stopSkipping)
+	data := um.getFileRowData()
+	logEvent(logger, slog.LevelDebug, "entering sect", rowAttrs(data, slog.String("section", s.currSect))...)
 	// if this sectname is new, then it will be added to conf.sectNames
 	if _, exists := conf.sects[s.currSect]; !exists {
 		conf.sectNames = append(conf.sectNames, s.currSect)
@@ -57,18 +70,30 @@ func (s *sectStrategy) execute(ctx *confContext.Context, conf *Config, um unMars
 // rowHandler for handling [include] like strings
 type includeStrategy struct {
 	fileName string
+	// sect to load key value files into. Empty means the current sect
+	sectName string
 }
 
 // create new include strategy
-func newIncludeStrategy(fileName string) *includeStrategy {
+func newIncludeStrategy(fileName, sectName string) *includeStrategy {
 	return &includeStrategy{
 		fileName: fileName,
+		sectName: sectName,
 	}
 }
 
 // handle strings like include, includeIfExist, includeIfExistWithBasePath
 func (i *includeStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
+	if i.sectName != "" {
+		currSect := ctx.RunTime.Params[confContext.CurrSect]
+		ctx.RunTime.SetCurrentSect(i.sectName)
+		defer ctx.RunTime.SetCurrentSect(currSect)
+	}
 	err := readConfigFile(ctx, i.fileName, conf, logger)
+	var pe *ParseError
+	if err != nil && !errors.As(err, &pe) {
+		return newRowError(KindInclude, -1, "%w", err)
+	}
 	return err
 }
 
@@ -90,20 +115,29 @@ func newMacroUseStrategy(name, params string, numParams int) *macroUseStrategy {
 // handle strings of type [section]
 func (mus *macroUseStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
 	// find macro
-	if macro, exists := conf.macros[mus.macroName]; !exists {
-		return fmt.Errorf("macro %v not found", mus.macroName)
-		// set param values
-	} else if err := macro.SetParamValues(&mus.macroParams, mus.numMacroParams, &conf.sects, ctx.RunTime.Params[confContext.CurrSect]); err != nil {
+	macro, exists := conf.macros[mus.macroName]
+	if !exists {
+		return newRowError(KindMacro, -1, "macro %v not found", mus.macroName)
+	}
+	macro.used = true
+
+	// set param values
+	if err := macro.SetParamValues(&mus.macroParams, mus.numMacroParams, conf, ctx.RunTime.Params[confContext.CurrSect]); err != nil {
 		return err
-		// Add macro props to sect
-	} else if ctx.RunTime.SaveTo == confContext.Sects {
-		if err := conf.addMacroPropsToSect(ctx, &mus.macroName); err != nil {
-			return err
+	}
+	data := um.getFileRowData()
+	logEvent(logger, slog.LevelDebug, "using macro", rowAttrs(data, slog.String("section", ctx.RunTime.Params[confContext.CurrSect]), slog.String("macro", mus.macroName), slog.Any("params", macro.parameters))...)
+
+	// Add macro props to sect
+	origin := newOrigin(ctx, data)
+	if ctx.RunTime.SaveTo == confContext.Sects {
+		if err := conf.addMacroPropsToSect(ctx, &mus.macroName, origin, logger); err != nil {
+			return withoutColumn(err)
 		}
 		// Add macro props to macro
 	} else {
-		if err := conf.addPropsToMacro(ctx, &mus.macroName); err != nil {
-			return err
+		if err := conf.addPropsToMacro(ctx, &mus.macroName, origin); err != nil {
+			return withoutColumn(err)
 		}
 	}
 	return nil
@@ -126,6 +160,8 @@ func newMacroDefineStrategy(name, params string) *macroDefineStrategy {
 func (m *macroDefineStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
 	ctx.RunTime.SetCurrentMacro(m.macroName)
 	conf.macros[m.macroName] = NewMacro(&m.macroParams)
+	conf.macros[m.macroName].definedAt = newOrigin(ctx, um.getFileRowData())
+	logEvent(logger, slog.LevelDebug, "defining macro", rowAttrs(um.getFileRowData(), slog.String("macro", m.macroName), slog.String("params", strings.ReplaceAll(m.macroParams, string(rune(0)), ", ")))...)
 	um.setSkipSectionMode(stopSkipping)
 	return nil
 }
@@ -135,6 +171,12 @@ type propertyStrategy struct {
 	rowType dataType
 	key     string
 	value   string
+	// items of a list value, like key = [a, b]. Nil if the value isn't a list
+	items []string
+	// set for rows like key[] = value
+	appendItem bool
+	// set for list values in macros
+	macroList bool
 }
 
 // create new propertyHandler
@@ -148,15 +190,129 @@ func newPropertyStrategy(rowType dataType, key string, value string) *propertySt
 
 // handle strings of type: property and multiline
 func (ps *propertyStrategy) execute(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger) error {
+	// remember where the property was set
+	data := um.getFileRowData()
+	origin := newOrigin(ctx, data)
+	if ctx.RunTime.SaveTo != confContext.Sects {
+		m := conf.getCurrentMacro(ctx)
+		m.origins[ps.key] = origin
+		m.setList(ps.key, ps.macroList)
+		return ps.setValue(ctx, conf, um)
+	}
+	sectName := ctx.RunTime.Params[confContext.CurrSect]
+	if ps.appendItem {
+		return ps.appendListItems(ctx, conf, um, sectName, origin)
+	}
+	err := ps.setSectValue(ctx, conf, um, logger, sectName, origin)
+	// the row decides if the property is a list, unless the previous value was kept
+	if conf.getOrigin(sectName, ps.key) == origin {
+		conf.setListItems(sectName, ps.key, ps.items)
+	}
+	return err
+}
+
+// add items from a row like key[] = value, or key[] = [a, b], to a list. A property that isn't a list is replaced
+func (ps *propertyStrategy) appendListItems(ctx *confContext.Context, conf *Config, um unMarshaller, sectName string, origin *Origin) error {
+	items := append([]string(nil), conf.lists[sectName][ps.key]...)
+	conf.setOrigin(sectName, ps.key, origin)
+	if err := ps.setValue(ctx, conf, um); err != nil {
+		return err
+	}
+	if ps.items != nil {
+		items = append(items, ps.items...)
+	} else {
+		items = append(items, conf.sects[sectName][ps.key])
+	}
+	conf.sects[sectName][ps.key] = strings.Join(items, "\n")
+	conf.setListItems(sectName, ps.key, items)
+	return nil
+}
+
+// set the value of a property in a sect, following the duplicate policy
+func (ps *propertyStrategy) setSectValue(ctx *confContext.Context, conf *Config, um unMarshaller, logger *Logger, sectName string, origin *Origin) error {
+	previous := conf.checkDuplicate(ctx, logger, sectName, ps.key, origin)
+	if previous == nil {
+		conf.setOrigin(sectName, ps.key, origin)
+		return ps.setValue(ctx, conf, um)
+	}
+
+	// the value is always read, so multiline values don't leave rows behind
+	prevValue := conf.sects[sectName][ps.key]
+	conf.setOrigin(sectName, ps.key, origin)
+	if err := ps.setValue(ctx, conf, um); err != nil {
+		return err
+	}
+	return conf.applyDuplicatePolicy(ctx, sectName, ps.key, prevValue, previous)
+}
+
+// set a property from a macro, or from an included file in another format, following the duplicate policy.
+// items are the items of a list value, or nil
+func (conf *Config) setPropByPolicy(ctx *confContext.Context, logger *Logger, sectName, key, value string, items []string, origin *Origin) error {
+	previous := conf.checkDuplicate(ctx, logger, sectName, key, origin)
+	if _, exists := conf.sects[sectName][key]; previous == nil || !exists {
+		conf.setProp(sectName, key, value, origin)
+		conf.setListItems(sectName, key, items)
+		return nil
+	}
+	prevValue := conf.sects[sectName][key]
+	conf.sects[sectName][key] = value
+	conf.setOrigin(sectName, key, origin)
+	err := conf.applyDuplicatePolicy(ctx, sectName, key, prevValue, previous)
+	// the new value decides if the property is a list
+	if conf.getOrigin(sectName, key) == origin {
+		conf.setListItems(sectName, key, items)
+	}
+	return err
+}
+
+// report a property set again, at another row than before. Returns where it was set before, or nil if
+// it is not a duplicate
+func (conf *Config) checkDuplicate(ctx *confContext.Context, logger *Logger, sectName, key string, origin *Origin) *Origin {
+	// the same row again, from a file included more than once, is not a duplicate
+	previous := conf.getOrigin(sectName, key)
+	if previous == nil || (previous.FileName == origin.FileName && previous.RowNumber == origin.RowNumber) {
+		return nil
+	}
+	conf.report.duplicateProps = append(conf.report.duplicateProps, DuplicateProp{Sect: sectName, Prop: key, Origin: origin, Previous: previous})
+	if ctx.Duplicates == confContext.DuplicateWarn {
+		logEvent(logger, slog.LevelWarn, "duplicate property", originAttrs(origin, slog.String("section", sectName), slog.String("key", key), slog.String("previous", previous.String()))...)
+	}
+	return previous
+}
+
+// apply the duplicate policy, after a property set at previous has got a new value
+func (conf *Config) applyDuplicatePolicy(ctx *confContext.Context, sectName, key, prevValue string, previous *Origin) error {
+	switch ctx.Duplicates {
+	case confContext.DuplicateFirstWins, confContext.DuplicateError:
+		conf.sects[sectName][key] = prevValue
+		conf.setOrigin(sectName, key, previous)
+		if ctx.Duplicates == confContext.DuplicateError {
+			return newRowError(KindDuplicate, -1, "property %v in [%v] is already set at %v", key, sectName, previous)
+		}
+	case confContext.DuplicateAccumulate:
+		conf.accumulateValue(sectName, key, prevValue)
+	}
+	return nil
+}
+
+// set the value of the property, reading more rows for multiline values
+func (ps *propertyStrategy) setValue(ctx *confContext.Context, conf *Config, um unMarshaller) error {
 	// if it is a multiLineHereDoc rowType then the value contains the hereDocMarker
 	if ps.rowType == multiLineHereDoc {
 		hereDocMarker := ps.value
+		numRows := 0
 
 		// Loop until we finds the other hereDoc
 		for {
 			if um.scan() {
+				// in lenient mode, a row with an error is left out, but the rest of the hereDoc is still read
 				if err := um.prepareData(ctx, conf); err != nil {
-					return err
+					data := um.getFileRowData()
+					if err := conf.handleRowError(ctx, data, err); err != nil {
+						return err
+					}
+					data.rowType = multiLineHereDoc
+					continue
 				}
 				data := um.getFileRowData()
 
@@ -164,8 +320,13 @@ func (ps *propertyStrategy) execute(ctx *confContext.Context, conf *Config, um u
 				if data.value == hereDocMarker {
 					break
 				}
-				// add data to property
-				conf.appendProperty(ctx, ps.key, data.value, "\n")
+				// add data to property. The first row replaces any previous value
+				if numRows == 0 {
+					conf.upsertProperty(ctx, ps.key, data.value)
+				} else {
+					conf.appendProperty(ctx, ps.key, data.value, "\n")
+				}
+				numRows++
 
 				// keep rowType = multiLineHereDoc, until we find the hereDocMarker
 				data.rowType = multiLineHereDoc
@@ -174,6 +335,10 @@ func (ps *propertyStrategy) execute(ctx *confContext.Context, conf *Config, um u
 				break
 			}
 		}
+		// an empty hereDoc gives an empty property
+		if numRows == 0 {
+			conf.upsertProperty(ctx, ps.key, "")
+		}
 		return nil
 	}
 
@@ -185,8 +350,16 @@ func (ps *propertyStrategy) execute(ctx *confContext.Context, conf *Config, um u
 		// Loop until we got a row without ending backslash
 		for {
 			if um.scan() {
+				// in lenient mode, a row with an error is left out, but the rows after it still belong to the value
 				if err := um.prepareData(ctx, conf); err != nil {
-					return err
+					data := um.getFileRowData()
+					if err := conf.handleRowError(ctx, data, err); err != nil {
+						return err
+					}
+					if data.rowType != multiLineBackslash {
+						break
+					}
+					continue
 				}
 				data := um.getFileRowData()
 
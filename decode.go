@@ -0,0 +1,293 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Name of the struct tag used by Unmarshal and Decode
+const tagName = "conf"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// DecodeError describes a property that could not be converted to the type of its struct field
+type DecodeError struct {
+	Sect     string
+	Prop     string
+	FileName string
+	Row      int
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("[%v] %v: %v", e.Sect, e.Prop, e.Err)
+	}
+	return fmt.Sprintf("%v:%v: [%v] %v: %v", e.FileName, e.Row, e.Sect, e.Prop, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors holds all errors found during one call to Unmarshal or Decode
+type DecodeErrors []*DecodeError
+
+func (errs DecodeErrors) Error() string {
+	var sb strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			sb.WriteRune('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Unmarshal copies sections into the struct pointed to by v. Fields tagged with `conf:"sectName"`
+// are decoded from that section with Sect.Decode. Fields without tag are ignored
+func (conf *Config) Unmarshal(v any) error {
+	rv, err := structPointer(v)
+	if err != nil {
+		return err
+	}
+	errs := DecodeErrors{}
+	conf.decodeSects(rv, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Decode copies properties of the sect into the struct pointed to by v. Fields tagged with `conf:"propName"`
+// gets the value of that property, converted to the type of the field, or read by UnmarshalText if the field
+// implements encoding.TextUnmarshaler, like time.Time. Other struct fields are decoded from the section named
+// in their tag and embedded structs are decoded from this sect
+func (sect *Sect) Decode(v any) error {
+	rv, err := structPointer(v)
+	if err != nil {
+		return err
+	}
+	errs := DecodeErrors{}
+	sect.decodeProps(rv, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// make sure v is a non nil pointer to a struct, and return the struct
+func structPointer(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return reflect.Value{}, fmt.Errorf("decode target must be a non nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("decode target must be a non nil pointer to a struct, got %T", v)
+	}
+	return rv, nil
+}
+
+// loop all fields of rv and decode each tagged field from its section
+func (conf *Config) decodeSects(rv reflect.Value, errs *DecodeErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+
+		// embedded structs without tag are treated as part of the parent struct
+		name, tagged := field.Tag.Lookup(tagName)
+		if !tagged {
+			if field.Anonymous && fv.Kind() == reflect.Struct {
+				conf.decodeSects(fv, errs)
+			}
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		sect := conf.Sect(name)
+		if !sect.Exists {
+			continue
+		}
+		if target, ok := structTarget(fv); ok {
+			sect.decodeProps(target, errs)
+		} else {
+			*errs = append(*errs, &DecodeError{Sect: name, Err: fmt.Errorf("field %v must be a struct", field.Name)})
+		}
+	}
+}
+
+// loop all fields of rv and set each tagged field from its property
+func (sect *Sect) decodeProps(rv reflect.Value, errs *DecodeErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+
+		// embedded structs without tag share the props of this sect
+		name, tagged := field.Tag.Lookup(tagName)
+		if !tagged {
+			if field.Anonymous && fv.Kind() == reflect.Struct {
+				sect.decodeProps(fv, errs)
+			}
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+
+		// nested structs are read from the section named in the tag
+		if isNestedStruct(field.Type) {
+			if nested := sect.conf.Sect(name); nested.Exists {
+				target, _ := structTarget(fv)
+				nested.decodeProps(target, errs)
+			}
+			continue
+		}
+
+		// skip missing props, leaving the field untouched
+		val, exists := sect.PropVal(name)
+		if !exists {
+			continue
+		}
+		// list props fill slices item by item
+		var err error
+		if items, isList := sect.conf.lists[sect.name][name]; isList && fv.Kind() == reflect.Slice && !isTextUnmarshaler(fv.Type()) {
+			err = setSlice(fv, items)
+		} else {
+			err = setField(fv, val)
+		}
+		if err != nil {
+			*errs = append(*errs, sect.newDecodeError(name, err))
+		}
+	}
+}
+
+// create a DecodeError, pointing out where the property was set
+func (sect *Sect) newDecodeError(propName string, err error) *DecodeError {
+	decodeErr := &DecodeError{
+		Sect: sect.name,
+		Prop: propName,
+		Err:  err,
+	}
+	if origin := sect.conf.getOrigin(sect.name, propName); origin != nil {
+		decodeErr.FileName = origin.FileName
+		decodeErr.Row = origin.RowNumber
+	}
+	return decodeErr
+}
+
+// check if t is a struct, or a pointer to a struct, that should be decoded from a section. Structs that
+// can unmarshal themselves from text, like time.Time, are read from a single property
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isTextUnmarshaler(t)
+}
+
+// check if a pointer to t implements encoding.TextUnmarshaler
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// return the struct that fv holds or points to. Nil pointers are allocated
+func structTarget(fv reflect.Value) (reflect.Value, bool) {
+	if fv.Kind() == reflect.Pointer {
+		if fv.Type().Elem().Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	return fv, fv.Kind() == reflect.Struct
+}
+
+// convert val to the type of fv, and set fv
+func setField(fv reflect.Value, val string) error {
+	// allocate pointers
+	if fv.Kind() == reflect.Pointer {
+		target := reflect.New(fv.Type().Elem())
+		if err := setField(target.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(target)
+		return nil
+	}
+
+	// types that can read themselves, like time.Time and net.IP
+	if isTextUnmarshaler(fv.Type()) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	// durations are int64, so they must be handled before ints
+	if fv.Type() == durationType {
+		d, err := parseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := parseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := parseInt(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := parseUint(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := parseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		return setSlice(fv, splitList(val))
+	default:
+		return fmt.Errorf("datatype %v not implemented", fv.Type())
+	}
+	return nil
+}
+
+// convert items to the item type of fv, and set fv
+func setSlice(fv reflect.Value, items []string) error {
+	slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := setField(slice.Index(i), item); err != nil {
+			return fmt.Errorf("item %v: %w", i, err)
+		}
+	}
+	fv.Set(slice)
+	return nil
+}
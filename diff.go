@@ -0,0 +1,114 @@
+package config
+
+import "sort"
+
+// ChangeKind tells how a property differs between two configs
+type ChangeKind int8
+
+const (
+	PropAdded ChangeKind = iota
+	PropRemoved
+	PropChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case PropAdded:
+		return "added"
+	case PropRemoved:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// PropChange describes one property that differs between two configs
+type PropChange struct {
+	Sect     string
+	Prop     string
+	Kind     ChangeKind
+	OldValue string
+	NewValue string
+}
+
+// ConfigDiff holds the differences between two configs
+type ConfigDiff struct {
+	AddedSects   []string
+	RemovedSects []string
+	Props        []PropChange
+}
+
+// Diff returns what has to be done to oldConf to turn it into newConf. Sects are listed in sectNames order and props by name
+func Diff(oldConf, newConf *Config) *ConfigDiff {
+	diff := &ConfigDiff{
+		AddedSects:   make([]string, 0),
+		RemovedSects: make([]string, 0),
+		Props:        make([]PropChange, 0),
+	}
+
+	// removed sects, and removed or changed props in sects that still exists
+	for _, sectName := range diffSectNames(oldConf) {
+		oldProps := oldConf.sects[sectName]
+		newProps, exists := newConf.sects[sectName]
+		if !exists {
+			diff.RemovedSects = append(diff.RemovedSects, sectName)
+		}
+		for _, propName := range sortedKeys(oldProps) {
+			if newVal, exists := newProps[propName]; !exists {
+				diff.Props = append(diff.Props, PropChange{Sect: sectName, Prop: propName, Kind: PropRemoved, OldValue: oldProps[propName]})
+			} else if newVal != oldProps[propName] {
+				diff.Props = append(diff.Props, PropChange{Sect: sectName, Prop: propName, Kind: PropChanged, OldValue: oldProps[propName], NewValue: newVal})
+			}
+		}
+	}
+
+	// added sects and added props
+	for _, sectName := range diffSectNames(newConf) {
+		oldProps, exists := oldConf.sects[sectName]
+		if !exists {
+			diff.AddedSects = append(diff.AddedSects, sectName)
+		}
+		newProps := newConf.sects[sectName]
+		for _, propName := range sortedKeys(newProps) {
+			if _, exists := oldProps[propName]; !exists {
+				diff.Props = append(diff.Props, PropChange{Sect: sectName, Prop: propName, Kind: PropAdded, NewValue: newProps[propName]})
+			}
+		}
+	}
+	return diff
+}
+
+// Check if there are no differences
+func (d *ConfigDiff) IsEmpty() bool {
+	return len(d.AddedSects) == 0 && len(d.RemovedSects) == 0 && len(d.Props) == 0
+}
+
+// sectNames in order, followed by sects only known by conf.sects
+func diffSectNames(conf *Config) []string {
+	names := make([]string, 0, len(conf.sects))
+	seen := make(map[string]struct{}, len(conf.sects))
+	for _, name := range conf.sectNames {
+		if _, done := seen[name]; done {
+			continue
+		}
+		if _, exists := conf.sects[name]; exists {
+			names = append(names, name)
+			seen[name] = struct{}{}
+		}
+	}
+	for _, name := range sortedKeys(conf.sects) {
+		if _, exists := seen[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	confContext "github.com/grufgran/config/context"
+	"github.com/grufgran/config/stringMask"
+)
+
+// Document is a conf file kept row by row, so it can be edited without loosing comments, blank rows,
+// claims, macro definitions or the layout of untouched rows. Includes are not followed, and constants
+// are kept as they are
+type Document struct {
+	fileName        string
+	rows            []*docRow
+	crlf            bool
+	endsWithNewline bool
+}
+
+// one row in the document, or several if it is a multiline property
+type docRow struct {
+	texts   []string
+	rowType dataType
+	sect    string
+	key     string
+	// set if the row is in a section with claims
+	claims string
+	// set if the row is part of a macro definition
+	inMacro bool
+}
+
+// Read fileName into a Document
+func LoadDocument(fileName string) (*Document, error) {
+	absFilename, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(absFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDocument(f, absFilename)
+}
+
+// Read r into a Document. fileName is used when saving and in error messages
+func ParseDocument(r io.Reader, fileName string) (*Document, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{
+		fileName:        fileName,
+		rows:            make([]*docRow, 0),
+		endsWithNewline: len(content) > 0 && content[len(content)-1] == '\n',
+	}
+	texts := strings.Split(string(content), "\n")
+	if doc.endsWithNewline || len(content) == 0 {
+		texts = texts[:len(texts)-1]
+	}
+	doc.crlf = len(texts) > 0 && strings.HasSuffix(texts[0], "\r")
+
+	// classify rows in rawMode, so nothing is evaluated
+	ctx := confContext.NewContext(nil)
+	ctx.RunTime.SetCurrentSect("")
+	conf := NewConfig()
+	frd := &fileRowData{
+		fileName: fileName,
+		fileDir:  filepath.Dir(fileName),
+		rawMode:  true,
+	}
+	var multiLine *docRow
+	hereDocMarker := ""
+	curr := &docRow{}
+	for i, text := range texts {
+		frd.row = strings.TrimSuffix(text, "\r")
+		frd.rowNumber = i + 1
+		frd.findings = make(map[findingsType]string, 2)
+		frd.prevRowType = frd.rowType
+		frd.rowType = unknown
+		if err := frd.setValueAndType(ctx, conf, false); err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", fileName, frd.rowNumber, err)
+		}
+
+		// rows belonging to a multiline property
+		if multiLine != nil {
+			multiLine.texts = append(multiLine.texts, text)
+			if multiLine.rowType == multiLineHereDoc {
+				if frd.value == hereDocMarker {
+					multiLine = nil
+				} else {
+					frd.rowType = multiLineHereDoc
+				}
+			} else if frd.rowType != multiLineBackslash {
+				multiLine = nil
+			}
+			continue
+		}
+
+		row := &docRow{
+			texts:   []string{text},
+			rowType: frd.rowType,
+			sect:    curr.sect,
+			claims:  curr.claims,
+			inMacro: curr.inMacro,
+		}
+		switch frd.rowType {
+		case section:
+			row.sect = frd.value
+			row.claims = frd.findings[sectClaims]
+			row.inMacro = false
+			curr = row
+		case macroDefine:
+			row.inMacro = true
+			curr = row
+		case property, multiLineBackslash, multiLineHereDoc:
+			row.key = frd.findings[key]
+			if frd.rowType != property {
+				multiLine = row
+				hereDocMarker = frd.findings[value]
+			}
+		}
+		doc.rows = append(doc.rows, row)
+	}
+	return doc, nil
+}
+
+// check if the row is a property in sectName, outside macro definitions
+func (row *docRow) isProp(sectName string) bool {
+	switch row.rowType {
+	case property, multiLineBackslash, multiLineHereDoc:
+		return !row.inMacro && row.sect == sectName
+	}
+	return false
+}
+
+// Set value of a property. If the property exists, the last occurrence is changed, keeping its
+// comment when possible. New properties are added last in the sect, and new sects last in the document.
+// Sects with claims are left as they are, like the properties in them
+func (doc *Document) SetProp(sectName, propName, value string) error {
+	if err := checkKey(propName); err != nil {
+		return err
+	}
+	for i := len(doc.rows) - 1; i >= 0; i-- {
+		if row := doc.rows[i]; row.isProp(sectName) && row.claims == "" && row.key == propName {
+			return doc.setValue(row, value)
+		}
+	}
+
+	// new property
+	prop, err := formatProp(propName, value)
+	if err != nil {
+		return err
+	}
+	i := doc.sectEnd(sectName)
+	if i == -1 {
+		if err := doc.AddSect(sectName); err != nil {
+			return err
+		}
+		i = len(doc.rows) - 1
+	}
+	row := &docRow{
+		texts:   doc.newTexts(prop),
+		rowType: property,
+		sect:    sectName,
+		key:     propName,
+	}
+	doc.rows = append(doc.rows[:i+1], append([]*docRow{row}, doc.rows[i+1:]...)...)
+	return nil
+}
+
+// change value of an existing property
+func (doc *Document) setValue(row *docRow, value string) error {
+	text := strings.TrimSuffix(row.texts[0], "\r")
+	runes := []rune(text)
+	eqPos := len([]rune(text[:strings.IndexRune(text, '=')]))
+
+	// a single row, stays a single row, with the comment kept
+	if row.rowType == property && !needsHereDoc(value) && !strings.ContainsRune(value, '\r') {
+		sm := stringMask.NewStringMask(text, '-', '#')
+		sm.MaskEscapes('\\', escapable, 'e', true)
+		valueEnd := len(runes)
+		if sm.Comment.Pos != -1 {
+			valueEnd = sm.Comment.Pos
+		}
+		valueStart := eqPos + 1
+		for valueStart < valueEnd && isSpace(runes[valueStart]) {
+			valueStart++
+		}
+		for valueEnd > valueStart && isSpace(runes[valueEnd-1]) {
+			valueEnd--
+		}
+		prefix := string(runes[:valueStart])
+		if valueStart == eqPos+1 && value != "" {
+			prefix += " "
+		}
+		row.texts = doc.newTexts(prefix + escape(value) + string(runes[valueEnd:]) + "\n")
+		return nil
+	}
+
+	// everything else is formatted again
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(string(runes[:eqPos])))
+	sb.WriteString(" =")
+	if err := formatValue(&sb, value); err != nil {
+		return err
+	}
+	row.texts = doc.newTexts(sb.String())
+	if needsHereDoc(value) {
+		row.rowType = multiLineHereDoc
+	} else if len(row.texts) > 1 {
+		row.rowType = multiLineBackslash
+	} else {
+		row.rowType = property
+	}
+	return nil
+}
+
+// index of the last header or property in the last sect named sectName without claims. -1 if not found
+func (doc *Document) sectEnd(sectName string) int {
+	end := -1
+	for i, row := range doc.rows {
+		if row.sect != sectName || row.claims != "" || row.inMacro {
+			continue
+		}
+		if row.rowType == section || row.isProp(sectName) {
+			end = i
+		}
+	}
+	return end
+}
+
+// Delete all occurrences of a property in a sect. Returns false if the property was not found
+func (doc *Document) DeleteProp(sectName, propName string) bool {
+	rows := doc.rows[:0]
+	deleted := false
+	for _, row := range doc.rows {
+		if row.isProp(sectName) && row.key == propName {
+			deleted = true
+			continue
+		}
+		rows = append(rows, row)
+	}
+	doc.rows = rows
+	return deleted
+}
+
+// Add an empty sect last in the document
+func (doc *Document) AddSect(sectName string) error {
+	if err := checkSectName(sectName); err != nil {
+		return err
+	}
+	for _, row := range doc.rows {
+		if row.rowType == section && row.sect == sectName && row.claims == "" {
+			return fmt.Errorf("sect %v already exists", sectName)
+		}
+	}
+	// separate from previous row with an empty row
+	if n := len(doc.rows); n > 0 {
+		lastTexts := doc.rows[n-1].texts
+		if strings.TrimSpace(lastTexts[len(lastTexts)-1]) != "" {
+			doc.rows = append(doc.rows, &docRow{texts: doc.newTexts("\n"), rowType: empty, sect: doc.rows[n-1].sect})
+		}
+	}
+	doc.rows = append(doc.rows, &docRow{
+		texts:   doc.newTexts("[" + sectName + "]\n"),
+		rowType: section,
+		sect:    sectName,
+	})
+	return nil
+}
+
+// split formatted rows into texts, using the line endings of the document
+func (doc *Document) newTexts(s string) []string {
+	texts := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if doc.crlf {
+		for i := range texts {
+			texts[i] += "\r"
+		}
+	}
+	return texts
+}
+
+// WriteTo writes the document. Untouched rows are written exactly as they were read
+func (doc *Document) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	for _, row := range doc.rows {
+		for _, text := range row.texts {
+			sb.WriteString(text)
+			sb.WriteRune('\n')
+		}
+	}
+	s := sb.String()
+	if !doc.endsWithNewline && len(doc.rows) > 0 {
+		s = strings.TrimSuffix(s, "\n")
+	}
+	n, err := io.WriteString(w, s)
+	return int64(n), err
+}
+
+// Save the document to the file it was read from. The file is replaced, so readers never see a half written file
+func (doc *Document) Save() error {
+	if doc.fileName == "" {
+		return fmt.Errorf("document has no file name")
+	}
+	f, err := os.CreateTemp(filepath.Dir(doc.fileName), filepath.Base(doc.fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := doc.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if info, err := os.Stat(doc.fileName); err == nil {
+		if err := f.Chmod(info.Mode()); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), doc.fileName)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\v'
+}
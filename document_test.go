@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	config "github.com/grufgran/config/context"
+)
+
+func TestDocumentEdit(t *testing.T) {
+	doc, err := LoadDocument("testdata/test1.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// untouched documents are written exactly as read
+	original, _ := os.ReadFile("testdata/test1.conf")
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(original) {
+		t.Fatalf("document changed without edits:\n%v", buf.String())
+	}
+
+	if err := doc.SetProp("", "property", "new value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetProp("sectionrole", "key3", "value3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetProp("sectionrole", "key1", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetProp("multiLine test", "multiLine1", "single"); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.DeleteProp("multiLine test", "multiLine2") {
+		t.Error("multiLine2 not deleted")
+	}
+	if doc.DeleteProp("usage1", "x") {
+		t.Error("props from macros are not part of the document")
+	}
+	buf.Reset()
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	edited := buf.String()
+
+	expected := []string{
+		"property = new value  # with a comment\n",
+		// the existing sect has claims, so a new one is added
+		"[include = ^/test2.conf]\n\n[sectionrole]\nkey3 = value3\nkey1 = changed",
+		"sectionrole]\nkey1=value1\n",
+		"# multiLineHereDoc test\nmultiLine1 = single\n\n# multLineBackslash test\n\n",
+		"[define myMacro($y, $z)]\nx=1\nc=[:testrole]\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(edited, e) {
+			t.Errorf("expected %q in:\n%v", e, edited)
+		}
+	}
+}
+
+func TestDocumentBlankRows(t *testing.T) {
+	text := "[s]\n  \nk = v\n\t\n"
+	doc, err := ParseDocument(strings.NewReader(text), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetProp("s", "k", "w"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[s]\n  \nk = w\n\t\n" {
+		t.Errorf("unexpected document:\n%q", buf.String())
+	}
+
+	ctx := config.NewContext(nil)
+	ctx.Lenient = true
+	conf, err := NewConfigFromString(ctx, text, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val, _ := conf.PropVal("s", "k"); val != "v" {
+		t.Errorf("expected v, got %q", val)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	confContext "github.com/grufgran/config/context"
+	"github.com/grufgran/config/stringMask"
+)
+
+// Replace all environment variables on row, like ${NAME}, ${ENV:NAME} or ${NAME:-default}
+func (conf *Config) replaceEnvVars(ctx *confContext.Context, sm *stringMask.StringMask, currentMask, setMaskTo rune) error {
+	dollars := sm.GetAllMaskPoints('$', currentMask)
+	for _, dollar := range *dollars {
+		// skip dollars already replaced, like in ${A:-$}
+		if sm.NewMaskPoint(dollar.Pos).Mask != currentMask {
+			continue
+		}
+		// a dollar must be followed by a left curly bracket
+		leftBracket := sm.NewMaskPoint(dollar.Pos + 1)
+		if leftBracket.Rune != '{' || leftBracket.Mask != currentMask {
+			continue
+		}
+		// and there must be a right one. Otherwise it is just text
+		rightBrackets := sm.GetMaskPoints(leftBracket.Pos+1, 1, 1, -1, []rune{'}'}, currentMask)
+		if len(*rightBrackets) == 0 {
+			continue
+		}
+		rightBracket := (*rightBrackets)[0]
+		expr := sm.GetStringBetween(leftBracket.Pos+1, rightBracket.Pos-1, false)
+		val, err := ctx.ExpandEnv(expr)
+		if err != nil {
+			return newRowError(KindEnv, dollar.Pos, "%w", err)
+		}
+		sm.MaskBetween(dollar.Pos, rightBracket.Pos, setMaskTo)
+		sm.NewTagAtPos(dollar.Pos, val)
+	}
+	return nil
+}
+
+// Override props with environment variables named <prefix><separator>SECT<separator>PROP, using the prefix
+// and separator in ctx. Sects and props are matched case insensitive, with any rune but letters and digits
+// matching an underscore. Unknown sects and props are added in lower case
+func (conf *Config) ApplyEnvOverrides(ctx *confContext.Context) {
+	if ctx.EnvOverridePrefix == "" {
+		return
+	}
+	layer := conf.envLayer(ctx, ctx.EnvOverridePrefix)
+	for _, sectName := range layer.sectNames {
+		for _, propName := range layer.propOrder(sectName) {
+			conf.setProp(sectName, propName, layer.sects[sectName][propName], layer.getOrigin(sectName, propName))
+		}
+	}
+}
+
+// props from environment variables named <prefix><separator>SECT<separator>PROP, with sect and prop
+// names matched against conf
+func (conf *Config) envLayer(ctx *confContext.Context, envPrefix string) *Config {
+	layer := NewConfig()
+	sep := ctx.GetEnvOverrideSeparator()
+	prefix := envPrefix + sep
+
+	// environment variables are applied in order, so the result doesn't depend on the order of the environment
+	vars := ctx.GetEnviron()
+	sort.Strings(vars)
+	for _, kv := range vars {
+		name, val, found := strings.Cut(kv, "=")
+		if !found || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		sectPart, propPart, found := strings.Cut(name[len(prefix):], sep)
+		if !found || sectPart == "" || propPart == "" {
+			continue
+		}
+		sectName := conf.findSectName(sectPart)
+		propName := findPropName(conf.propOrder(sectName), propPart)
+		layer.setProp(sectName, propName, val, &Origin{EnvVar: name})
+	}
+	return layer
+}
+
+// find sect matching envName, or a new lower case sect name
+func (conf *Config) findSectName(envName string) string {
+	for _, sectName := range conf.sectNames {
+		if envNormalize(sectName) == envNormalize(envName) {
+			return sectName
+		}
+	}
+	return strings.ToLower(envName)
+}
+
+// find prop matching envName among propNames, or a new lower case prop name
+func findPropName(propNames []string, envName string) string {
+	for _, propName := range propNames {
+		if envNormalize(propName) == envNormalize(envName) {
+			return propName
+		}
+	}
+	return strings.ToLower(envName)
+}
+
+// upper case, and everything except letters and digits replaced with _
+func envNormalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, s)
+}
@@ -1,9 +1,10 @@
 package config
 
 import (
-	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
+	"unicode"
 
 	config "github.com/grufgran/config/context"
 	"github.com/grufgran/config/stringMask"
@@ -33,6 +34,15 @@ const (
 	macroName
 	macroParams
 	numMacroParams
+	sectClaims
+	skippedSectName
+	includeSect
+	// items of a list value, like key = [a, b], separated by rune(0)
+	listItems
+	// set for rows like key[] = value, adding an item to a list
+	listAppend
+	// set for list values in macros, that are split when the macro is used
+	macroList
 )
 
 type fileRowData struct {
@@ -44,6 +54,16 @@ type fileRowData struct {
 	rowType     dataType
 	prevRowType dataType
 	findings    map[findingsType]string
+	// in rawMode rows are only classified. Constants, escapes and claims are left as they are, and includes are not checked
+	rawMode bool
+}
+
+// runes that can be escaped with a backslash, to loose their special meaning
+const escapable = "#[]\\$"
+
+// Mask escaped runes. While defining macros, the escapes are kept, since the macro body is parsed again when the macro is used
+func (frd *fileRowData) maskEscapes(ctx *config.Context, sm *stringMask.StringMask) {
+	sm.MaskEscapes('\\', escapable, 'e', frd.rawMode || ctx.RunTime.SaveTo == config.Macros)
 }
 
 // mask row and set rowType
@@ -51,6 +71,7 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 
 	// Init cmask
 	sm := stringMask.NewStringMask(frd.row, '-', '#')
+	frd.maskEscapes(ctx, sm)
 
 	// if we have an ending string like this  "....\ # comment", we need to mask white space after the backslash.
 	// we only do this, when there is a comment marker. An ending backslash means multiline. But then the backslash
@@ -63,7 +84,7 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 		// mask white trailing space
 		sm.MaskEndSpaces('X', '-')
 
-	} else if sm.Comment.Pos == 0 {
+	} else if sm.Comment.Pos == 0 && frd.prevRowType != multiLineHereDoc {
 		// we have a comment string, like this: "# this is a comment"
 		frd.rowType = comment
 		frd.value = ""
@@ -80,17 +101,11 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 		// in multiLineHereDoc mode, #-characters are allowed. I e they are not comment marks, so recreate the stringmask
 		if frd.prevRowType == multiLineHereDoc && sm.Comment.Pos != -1 {
 			sm = stringMask.NewStringMask(frd.row, '-')
+			frd.maskEscapes(ctx, sm)
 		}
 
-		// mask and replace constants.
-		// But, if we are in macroDefine mode, no constants shall be replaces. Those constants will be replaces during macroUse
-		if ctx.RunTime.SaveTo != config.Macros {
-			if err := conf.replaceConstants(sm, '-', 'C'); err != nil {
-				return err
-			}
-		}
-
-		// Mask ending backslash if such backslash exists
+		// Mask ending backslash if such backslash exists. This is done first, so the multiline value
+		// goes on, even if a constant on this row is wrong
 		if frd.prevRowType == multiLineBackslash && lastPoint != nil {
 			// is the last rune a backslash?
 			if lastPoint.Rune == '\\' {
@@ -98,8 +113,19 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 				frd.rowType = multiLineBackslash
 			}
 		}
+
+		// mask and replace constants.
+		// But, if we are in macroDefine mode, no constants shall be replaces. Those constants will be replaces during macroUse
+		if ctx.RunTime.SaveTo != config.Macros && !frd.rawMode {
+			if err := conf.replaceEnvVars(ctx, sm, '-', 'C'); err != nil {
+				return err
+			}
+			if err := conf.replaceConstants(sm, '-', 'C'); err != nil {
+				return err
+			}
+		}
 		// set value and exit
-		frd.value = sm.GetString('-', 'C')
+		frd.value = sm.GetString('-', 'C', 'e')
 		return nil
 	}
 
@@ -116,6 +142,12 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 	// get first and last rune where mask is '-'
 	startPoint := sm.GetFirstMaskPoint('-')
 	endPoint := sm.GetLastMaskPoint('-')
+	// a row with nothing but white space
+	if startPoint == nil {
+		frd.rowType = empty
+		frd.value = ""
+		return nil
+	}
 	// If the runes are [ and ] then this is a section
 	if startPoint.Rune == '[' && endPoint.Rune == ']' {
 		// mask first [ and last ]
@@ -123,11 +155,19 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 		sm.MaskAtPos(endPoint.Pos, ']')
 
 		// mask and get claims
-		if claimsFulfilled, err := conf.hasRequiredClaims(sm, '-', 'c', ctx); err != nil {
+		claimsFulfilled, err := conf.hasRequiredClaims(sm, '-', 'c', ctx, !frd.rawMode)
+		if err != nil {
 			// return the error
 			return err
-		} else if !claimsFulfilled {
+		}
+		// remember the claims, if there were any
+		questionMark := sm.GetFirstMaskPoint('?')
+		if questionMark != nil {
+			frd.findings[sectClaims] = sm.GetStringBetween(startPoint.Pos+1, questionMark.Pos-1, true)
+		}
+		if !claimsFulfilled {
 			// if not required claims are present, we have to skip this section
+			frd.findings[skippedSectName] = sm.GetStringBetween(questionMark.Pos+1, endPoint.Pos-1, true)
 			frd.value = frd.row
 			frd.rowType = skipSection
 			return nil
@@ -137,13 +177,13 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 		sm.MaskRightSpacesFromPos(startPoint.Pos+1, 'X', '-')
 		sm.MaskLeftSpacesFromPos(endPoint.Pos-1, 'X', '-')
 		frd.rowType = section
-		frd.value = sm.GetString('-')
+		frd.value = sm.GetString('-', 'e')
 
 		// check if there is a =-sign and frd.value starts with include
 		if equalSign := sm.MaskFirst('=', '=', '-'); equalSign != nil {
 			if strings.HasPrefix(frd.value, "include") {
 				sm.MaskLeftRightSpacesAround(equalSign.Pos, 'X', '-')
-				if err := frd.handleIncludes(ctx, sm); err != nil {
+				if err := frd.handleIncludes(ctx, conf, sm); err != nil {
 					return err
 				}
 			}
@@ -175,7 +215,7 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 	if equalSign == nil {
 		frd.rowType = unknown
 		frd.value = sm.GetString('-')
-		return fmt.Errorf("unkonwn rowtype: %v, at rownumber %v in file %v", frd.row, frd.rowNumber, frd.fileName)
+		return newRowError(KindSyntax, -1, "unknown rowtype: %v", frd.row)
 	} else {
 		frd.rowType = property
 	}
@@ -188,14 +228,41 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 		frd.rowType = multiLineBackslash
 	}
 
+	// lists are split in sects. Macros are parsed again when used, so there they are only remembered
+	isList := false
+	if ctx.RunTime.SaveTo == config.Sects && !frd.rawMode {
+		frd.maskListAppend(sm, equalSign.Pos)
+		if frd.rowType == property {
+			isList = maskListBrackets(sm, equalSign.Pos)
+		}
+	} else if !frd.rawMode {
+		// macro props are set once per key, so repeated key[] rows would lose items
+		if frd.maskListAppend(sm, equalSign.Pos) {
+			return newRowError(KindMacro, -1, "key[] rows can not be used in macros, use key = [a, b] instead: %v", frd.row)
+		}
+		if _, _, found := findListBrackets(sm, equalSign.Pos); found && frd.rowType == property {
+			frd.findings[macroList] = "true"
+		}
+	}
+
 	// mask and replace constants
 	// But, if we are in macroDefine mode, no constants shall be replaces. Those constants will be replaces during macroUse
-	if ctx.RunTime.SaveTo != config.Macros {
+	if ctx.RunTime.SaveTo != config.Macros && !frd.rawMode {
+		if err := conf.replaceEnvVars(ctx, sm, '-', 'C'); err != nil {
+			return err
+		}
 		if err := conf.replaceConstants(sm, '-', 'C'); err != nil {
 			return err
 		}
 	}
-	kvp := sm.GetStrings('-', 'C')
+	if isList {
+		return frd.setListItems(sm)
+	}
+	kvp := sm.GetStrings('-', 'C', 'e')
+	// a property without value, like "key ="
+	if len(kvp) == 1 {
+		kvp = append(kvp, "")
+	}
 	frd.findings[key] = kvp[0]
 	// check if kvp[1] is a hereDocMarker
 	if isHereDocType(&kvp[1]) {
@@ -213,6 +280,163 @@ func (frd *fileRowData) setValueAndType(ctx *config.Context, conf *Config, inSki
 	return nil
 }
 
+// mask an unescaped [] ending the key, like in key[] = value. Returns true if found
+func (frd *fileRowData) maskListAppend(sm *stringMask.StringMask, equalSignPos int) bool {
+	end := equalSignPos - 1
+	for end >= 0 && sm.NewMaskPoint(end).Mask == 'X' {
+		end--
+	}
+	// there must be a key before the brackets
+	if end < 2 {
+		return false
+	}
+	left, right := sm.NewMaskPoint(end-1), sm.NewMaskPoint(end)
+	if left.Rune == '[' && left.Mask == '-' && right.Rune == ']' && right.Mask == '-' {
+		sm.MaskBetween(left.Pos, right.Pos, 'l')
+		frd.findings[listAppend] = "true"
+		return true
+	}
+	return false
+}
+
+// mask the brackets around a value like [a, b, "c,d"] or [], before constants are replaced. Escaped brackets are text.
+// equalSignPos is -1 if sm holds only the value
+func maskListBrackets(sm *stringMask.StringMask, equalSignPos int) bool {
+	start, end, found := findListBrackets(sm, equalSignPos)
+	if !found {
+		return false
+	}
+	sm.MaskAtPos(start, 'l')
+	sm.MaskAtPos(end, 'l')
+	// trim white space inside the brackets
+	sm.MaskRightSpacesFromPos(start+1, 'X', '-')
+	sm.MaskLeftSpacesFromPos(end-1, 'X', '-')
+	return true
+}
+
+// find the brackets around a list value
+func findListBrackets(sm *stringMask.StringMask, equalSignPos int) (int, int, bool) {
+	start := equalSignPos + 1
+	for start < len(sm.String) && sm.NewMaskPoint(start).Mask == 'X' {
+		start++
+	}
+	endPoint := sm.GetLastMaskPoint('-')
+	if start >= len(sm.String) || endPoint == nil || endPoint.Pos <= start {
+		return 0, 0, false
+	}
+	if startPoint := sm.NewMaskPoint(start); startPoint.Rune != '[' || startPoint.Mask != '-' || endPoint.Rune != ']' {
+		return 0, 0, false
+	}
+	return start, endPoint.Pos, isListBetween(sm, start, endPoint.Pos)
+}
+
+// check that the brackets at start and end belong together, and that the value is meant as a list.
+// Only an empty list, or a comma outside quotes and inner brackets, makes it a list. Otherwise values
+// like [DRAFT] or [sect:prop] are read as they always were
+func isListBetween(sm *stringMask.StringMask, start, end int) bool {
+	depth := 0
+	quoted := false
+	empty, comma := true, false
+	for pos := start + 1; pos < end; pos++ {
+		point := sm.NewMaskPoint(pos)
+		if point.Mask != '-' || !unicode.IsSpace(point.Rune) {
+			empty = false
+		}
+		if point.Mask != '-' {
+			continue
+		}
+		switch {
+		case point.Rune == '"':
+			quoted = !quoted
+		case quoted:
+		case point.Rune == '[':
+			depth++
+		case point.Rune == ']':
+			// the first bracket is closed before the end, like [a:b]:[c:d]
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case depth > 0:
+		case point.Rune == ',':
+			comma = true
+		}
+	}
+	return comma || empty
+}
+
+// split a list row on commas outside quotation marks
+func (frd *fileRowData) setListItems(sm *stringMask.StringMask) error {
+	items, err := splitListItems(sm, 'C')
+	if err != nil {
+		return err
+	}
+	frd.findings[key] = items[0]
+	frd.findings[listItems] = strings.Join(items[1:], string(rune(0)))
+	frd.value = items[0] + "=[" + strings.Join(items[1:], ", ") + "]"
+	return nil
+}
+
+// split a masked list on commas outside quotation marks. Quoted items are kept as they are, even if empty.
+// Runes masked with replaced are constants or parameters, and their tags are used
+func splitListItems(sm *stringMask.StringMask, replaced rune) ([]string, error) {
+	quots, even := maskQuotes(sm)
+	if !even {
+		return nil, newRowError(KindSyntax, (*quots)[len(*quots)-1].Pos, "list with uneven number of quotation marks: %v", string(sm.String))
+	}
+	commas := sm.Mask(',', 'd', '-')
+	sm.MaskLeftRightSpacesAroundPoints(commas, 'X', '-')
+	unmaskQuotes(sm, quots)
+	// an opening quotation mark starts an item, even if nothing follows
+	for i := 0; i < len(*quots); i += 2 {
+		sm.MaskAtPos((*quots)[i].Pos, 'C')
+		sm.NewTagAtPos((*quots)[i].Pos, "")
+	}
+	return sm.GetStrings('-', replaced, 'C', 'e'), nil
+}
+
+// mask quotation marks with '"' and everything between them with 'q', so commas and parenthesis within
+// quotes are left alone. Returns the quotation marks, and if there was an even number of them
+func maskQuotes(sm *stringMask.StringMask) (*[]stringMask.MaskPoint, bool) {
+	quots := sm.Mask('"', '"', '-')
+	if len(*quots)%2 != 0 {
+		return quots, false
+	}
+	for i := 0; i < len(*quots); i += 2 {
+		remask(sm, (*quots)[i].Pos+1, (*quots)[i+1].Pos-1, '-', 'q')
+	}
+	return quots, true
+}
+
+// unmask everything between the quotation marks. Escapes within quotes are left as they are
+func unmaskQuotes(sm *stringMask.StringMask, quots *[]stringMask.MaskPoint) {
+	for i := 0; i < len(*quots); i += 2 {
+		remask(sm, (*quots)[i].Pos+1, (*quots)[i+1].Pos-1, 'q', '-')
+	}
+}
+
+// mask runes between start and end, that have currentMask, with setMaskTo
+func remask(sm *stringMask.StringMask, start, end int, currentMask, setMaskTo rune) {
+	for pos := start; pos <= end; pos++ {
+		if sm.NewMaskPoint(pos).Mask == currentMask {
+			sm.MaskAtPos(pos, setMaskTo)
+		}
+	}
+}
+
+// check if the row is meant to start a sect, or a macro definition. Used for rows that could not be parsed
+func (frd *fileRowData) opensSect() bool {
+	if frd.prevRowType == multiLineBackslash || frd.prevRowType == multiLineHereDoc {
+		return false
+	}
+	row := strings.TrimSpace(frd.row)
+	if !strings.HasPrefix(row, "[") {
+		return false
+	}
+	name := strings.TrimSpace(row[1:])
+	return !strings.HasPrefix(name, "include") && !strings.HasPrefix(name, "use ")
+}
+
 func isHereDocType(s *string) bool {
 	counter := 0
 	for _, r := range *s {
@@ -228,10 +452,10 @@ func isHereDocType(s *string) bool {
 	return false
 }
 
-func (frd *fileRowData) handleIncludes(ctx *config.Context, sm *stringMask.StringMask) error {
+func (frd *fileRowData) handleIncludes(ctx *config.Context, conf *Config, sm *stringMask.StringMask) error {
 
 	// get the include type and the file to read
-	items := sm.GetStrings('-')
+	items := sm.GetStrings('-', 'e')
 	switch items[0] {
 	case "include":
 		frd.rowType = include
@@ -241,22 +465,42 @@ func (frd *fileRowData) handleIncludes(ctx *config.Context, sm *stringMask.Strin
 		frd.rowType = includeIfExistWithBasePath
 	}
 
+	// key value files may be loaded into a named sect, like [include = secrets.env > secrets]
+	if fileName, sectName, found := strings.Cut(items[1], " > "); found {
+		items[1] = strings.TrimSpace(fileName)
+		if !isKeyValueFormat(items[1]) {
+			return newRowError(KindInclude, -1, "a sect can only be given for .properties and .env files: %v", frd.row)
+		}
+		frd.findings[includeSect] = strings.TrimSpace(sectName)
+	}
+
+	// in rawMode, the file is just remembered
+	if frd.rawMode {
+		frd.findings[filePath] = items[1]
+		return nil
+	}
+
 	// check if basePath is provided when we have a includeIfExistWithBasePath
 	basePath, err := frd.getBasePath(ctx, &items[0])
 	if err != nil {
-		return err
+		return newRowError(KindInclude, -1, "%w", err)
 	}
 	// check if the file exists
 	if fileExists, fileName, err := ctx.CheckIfFileExists(&frd.fileDir, &items[1], basePath); err != nil {
-		return err
+		return newRowError(KindInclude, -1, "%w", err)
 	} else if fileExists {
 		frd.findings[filePath] = *fileName
+		logEvent(conf.logger, slog.LevelDebug, "include resolved", rowAttrs(frd, slog.String("include", items[1]), slog.String("path", *fileName))...)
 	} else {
 		// if it is an include=someFile, we must return an error if the file doesn't exsist
 		if frd.rowType == include {
-			return fmt.Errorf("file %s not found from %s", *fileName, frd.value)
+			return newRowError(KindInclude, -1, "file %s not found from %s", *fileName, frd.value)
 		}
 		// when it is include_if_exists or include_site_if_exists, then we just skips the section
+		// but remember the file, since it may show up later
+		logEvent(conf.logger, slog.LevelDebug, "optional include skipped", rowAttrs(frd, slog.String("include", items[1]), slog.String("path", *fileName))...)
+		conf.filesMissing = append(conf.filesMissing, *fileName)
+		conf.report.missingIncludes = append(conf.report.missingIncludes, MissingInclude{FileName: *fileName, Origin: newOrigin(ctx, frd)})
 		frd.rowType = skipSection
 	}
 	return nil
@@ -293,16 +537,10 @@ func (frd *fileRowData) extractMacroNameAndParams(identifier string, sm *stringM
 
 	// mask all "-chars. Because a parameter could look like this:
 	// [use myMacro(1, "2,)")]
-	quots := sm.Mask('"', '"', '-')
 	// there must be even num of quots
-	if len(*quots) > 0 {
-		if len(*quots)%2 != 0 {
-			return fmt.Errorf("macro definition with uneven number of quotation marks: %v", frd.row)
-		}
-		// mask everything between them
-		for i := 0; i < len(*quots); i += 2 {
-			sm.MaskBetween((*quots)[i].Pos+1, (*quots)[i+1].Pos-1, 'q')
-		}
+	quots, even := maskQuotes(sm)
+	if !even {
+		return newRowError(KindMacro, (*quots)[len(*quots)-1].Pos, "macro definition with uneven number of quotation marks: %v", frd.row)
 	}
 
 	// Mask parenthesis
@@ -319,12 +557,7 @@ func (frd *fileRowData) extractMacroNameAndParams(identifier string, sm *stringM
 		sm.MaskLeftRightSpacesAroundPoints(commas, 'X', '-')
 
 		// unmask between quotation marks, if there were some
-		if len(*quots) > 0 {
-			// mask everything between them
-			for i := 0; i < len(*quots); i += 2 {
-				sm.MaskBetween((*quots)[i].Pos+1, (*quots)[i+1].Pos-1, '-')
-			}
-		}
+		unmaskQuotes(sm, quots)
 		// get macroname and params
 		items := sm.GetStrings('-')
 		frd.findings[macroName] = items[0]
@@ -338,9 +571,9 @@ func (frd *fileRowData) extractMacroNameAndParams(identifier string, sm *stringM
 		// macro without parenthesis
 		frd.findings[macroName] = sm.GetString('-')
 	} else if leftPar == nil {
-		return fmt.Errorf("macro definition without left parenthesis: %v", frd.row)
+		return newRowError(KindMacro, rightPar.Pos, "macro definition without left parenthesis: %v", frd.row)
 	} else {
-		return fmt.Errorf("macro definition without right parenthesis: %v", frd.row)
+		return newRowError(KindMacro, leftPar.Pos, "macro definition without right parenthesis: %v", frd.row)
 	}
 	return nil
 }
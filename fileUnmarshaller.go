@@ -2,8 +2,8 @@ package config
 
 import (
 	"bufio"
-	"fmt"
-	"os"
+	"io"
+	"log/slog"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -17,13 +17,13 @@ type fileUnmarshaller struct {
 	inSkipSectionMode bool
 }
 
-func newFileUnmarshaller(f *os.File, absFilename string) *fileUnmarshaller {
+func newFileUnmarshaller(r io.Reader, absFilename, fileDir string) *fileUnmarshaller {
 
 	fum := fileUnmarshaller{
-		scanner: bufio.NewScanner(f),
+		scanner: bufio.NewScanner(r),
 		data: &fileRowData{
 			fileName: absFilename,
-			fileDir:  filepath.Dir(absFilename),
+			fileDir:  fileDir,
 		},
 	}
 	return &fum
@@ -54,6 +54,10 @@ func (fum *fileUnmarshaller) prepareData(ctx *conf.Context, conf *Config) error
 		}
 	}
 	if err != nil {
+		// in lenient mode the rows of a sect with a bad header are skipped, instead of ending up in the sect before
+		if ctx.Lenient && fum.data.opensSect() {
+			fum.inSkipSectionMode = true
+		}
 		return err
 	}
 	return nil
@@ -88,12 +92,21 @@ func (fum *fileUnmarshaller) getDataStrategy(ctx *conf.Context) dataStrategy {
 		key := fum.data.findings[key]
 		value := fum.data.findings[value]
 		ps := newPropertyStrategy(fum.data.rowType, key, value)
+		if items, isList := fum.data.findings[listItems]; isList {
+			ps.items = make([]string, 0)
+			if items != "" {
+				ps.items = strings.Split(items, string(rune(0)))
+			}
+			ps.value = strings.Join(ps.items, "\n")
+		}
+		_, ps.appendItem = fum.data.findings[listAppend]
+		_, ps.macroList = fum.data.findings[macroList]
 		return ps
 
 		// if we found a include, then start read the new file
 	case include, includeIfExist, includeIfExistWithBasePath:
 		fileName := fum.data.findings[filePath]
-		return newIncludeStrategy(fileName)
+		return newIncludeStrategy(fileName, fum.data.findings[includeSect])
 
 		// if we found a macroDefine, handle it properly
 	case macroDefine:
@@ -115,7 +128,7 @@ func (fum *fileUnmarshaller) getDataStrategy(ctx *conf.Context) dataStrategy {
 func readConfigFile(ctx *conf.Context, filename string, conf *Config, logger *Logger) error {
 
 	// get absolutepath for file
-	absFilename, err := filepath.Abs(filename)
+	absFilename, err := ctx.AbsPath(filename)
 	if err != nil {
 		return err
 	}
@@ -126,29 +139,41 @@ func readConfigFile(ctx *conf.Context, filename string, conf *Config, logger *Lo
 	}
 
 	// open file
-	f, err := os.Open(filename)
+	f, err := ctx.Open(absFilename)
 	if err != nil {
 		// if file exist in same folder as the executable, then use that. file must not contain any /-characters
-		if strings.Contains(filepath.ToSlash(filename), "/") {
+		if ctx.FS != nil || strings.Contains(filepath.ToSlash(filename), "/") {
 			return err
 		}
-		absFilename = fmt.Sprintf("%v%v", ctx.GetExeFolder(), filename)
+		absFilename = filepath.Join(ctx.GetExeFolder(), filename)
 		if ctx.Stack.Contains(&absFilename) {
 			return nil
 		}
-		if exef, exeErr := os.Open(absFilename); exeErr != nil {
+		if exef, exeErr := ctx.Open(absFilename); exeErr != nil {
 			return err
 		} else {
 			f = exef
 		}
 	}
-	conf.filesUsed = append(conf.filesUsed, absFilename)
 
 	// remember to close the file at the end of the program
 	defer f.Close()
 
+	// files in other formats, like yaml, are read by their own loaders
+	if load, exists := getFormatLoader(absFilename); exists {
+		return readFormat(ctx, load, f, absFilename, conf, logger)
+	}
+	return readConfig(ctx, f, absFilename, conf, logger)
+}
+
+// read config rows from r. absFilename is used for resolving includes and must not be in the stack
+func readConfig(ctx *conf.Context, r io.Reader, absFilename string, conf *Config, logger *Logger) error {
+	conf.filesUsed = append(conf.filesUsed, absFilename)
+	conf.logger = logger
+	logEvent(logger, slog.LevelDebug, "reading file", slog.String("file", absFilename))
+
 	// set scanner
-	fum := newFileUnmarshaller(f, absFilename)
+	fum := newFileUnmarshaller(r, absFilename, ctx.Dir(absFilename))
 
 	// Add filename to stack
 	ctx.Stack.Push(absFilename)
@@ -165,5 +190,6 @@ func readConfigFile(ctx *conf.Context, filename string, conf *Config, logger *Lo
 
 	// pop info from ctx.stack
 	ctx.Stack.Pop()
-	return err
+	logEvent(logger, slog.LevelDebug, "done reading file", slog.String("file", absFilename))
+	return nil
 }
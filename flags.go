@@ -0,0 +1,105 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// Flags connects a flag.FlagSet with config. -set sect.prop=value overrides properties after parsing,
+// while -claim and -basepath name=dir are added to the context while the flags are parsed. Flags for single
+// properties are added with Prop or SchemaProps
+type Flags struct {
+	ctx  *confContext.Context
+	sets []flagSetting
+}
+
+// one property value given on the command line
+type flagSetting struct {
+	sect  string
+	prop  string
+	value string
+	flag  string
+}
+
+// Register -set, -claim and -basepath on fs. Claims and base paths are added to ctx, which must be
+// the context later used for parsing
+func NewFlags(fs *flag.FlagSet, ctx *confContext.Context) *Flags {
+	f := &Flags{
+		ctx:  ctx,
+		sets: make([]flagSetting, 0),
+	}
+	fs.Var(flagFunc(f.set), "set", "override property, like sect.prop=value. May be repeated")
+	fs.Var(flagFunc(f.claim), "claim", "add claim. May be repeated")
+	fs.Var(flagFunc(f.basePath), "basepath", "add base path, like name=dir. May be repeated")
+	return f
+}
+
+// Register a flag named sect.prop on fs, that overrides the property when set
+func (f *Flags) Prop(fs *flag.FlagSet, sectName, propName, usage string) {
+	name := sectName + "." + propName
+	fs.Var(flagFunc(func(value string) error {
+		f.sets = append(f.sets, flagSetting{sect: sectName, prop: propName, value: value, flag: "-" + name})
+		return nil
+	}), name, usage)
+}
+
+// Apply all properties given on the command line to conf, in the order they were given
+func (f *Flags) Apply(conf *Config) {
+	for _, s := range f.sets {
+		conf.setProp(s.sect, s.prop, s.value, &Origin{Flag: s.flag})
+	}
+}
+
+// -set sect.prop=value. The prop name starts after the last dot before the =-sign
+func (f *Flags) set(s string) error {
+	name, value, found := strings.Cut(s, "=")
+	if !found {
+		return fmt.Errorf("expected sect.prop=value, got %v", s)
+	}
+	name = strings.TrimSpace(name)
+	i := strings.LastIndex(name, ".")
+	if i < 1 || i == len(name)-1 {
+		return fmt.Errorf("expected sect.prop=value, got %v", s)
+	}
+	f.sets = append(f.sets, flagSetting{sect: name[:i], prop: name[i+1:], value: value, flag: "-set"})
+	return nil
+}
+
+func (f *Flags) claim(s string) error {
+	f.ctx.AddClaims(strings.Split(s, ",")...)
+	return nil
+}
+
+func (f *Flags) basePath(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("expected name=dir, got %v", s)
+	}
+	return f.ctx.AddBasePaths(s)
+}
+
+// flag.Value calling a func for every occurrence of the flag
+type flagFunc func(string) error
+
+func (fn flagFunc) String() string {
+	return ""
+}
+
+func (fn flagFunc) Set(s string) error {
+	return fn(s)
+}
+
+// Register a flag named sect.prop for every property described by schema. Flags already on fs, like
+// ones added with Prop, are left as they are
+func (f *Flags) SchemaProps(fs *flag.FlagSet, schema *Schema) {
+	for _, ss := range schema.sects {
+		for _, ps := range ss.props {
+			if fs.Lookup(ss.name+"."+ps.name) != nil {
+				continue
+			}
+			f.Prop(fs, ss.name, ps.name, ps.String())
+		}
+	}
+}
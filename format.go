@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// reads a file in another format than conf files, into conf through fr
+type formatLoader func(fr *formatReader, data []byte) error
+
+// loaders by file extension. Files with other extensions are read as conf files
+var formatLoaders = map[string]formatLoader{
+	".json": readJSON,
+	".yaml": readYAML,
+	".yml":  readYAML,
+	".toml": readTOML,
+	// key value files, loaded into the current sect
+	".properties": readProperties,
+	".env":        readDotenv,
+}
+
+// find loader for fileName, if it is not a conf file
+func getFormatLoader(fileName string) (formatLoader, bool) {
+	load, exists := formatLoaders[strings.ToLower(filepath.Ext(fileName))]
+	return load, exists
+}
+
+// check if fileName is loaded into a single sect
+func isKeyValueFormat(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".properties", ".env":
+		return true
+	}
+	return false
+}
+
+// adds sects and props from a file in another format to conf, remembering where they came from
+type formatReader struct {
+	conf     *Config
+	fileName string
+	// sect for key value files
+	currSect string
+	// files that included fileName, starting with the root conf file
+	includedFrom []string
+	// set when fileName is included from a conf file. Props already set follow its duplicate policy
+	ctx    *confContext.Context
+	logger *Logger
+}
+
+// add an empty sect, if it doesn't exist
+func (fr *formatReader) sect(sectName string) {
+	fr.conf.addSect(sectName)
+}
+
+// set a property found at row. In lenient mode duplicate errors are remembered, and nil is returned
+func (fr *formatReader) prop(sectName, key, value string, row int) error {
+	return fr.setProp(sectName, key, value, nil, row)
+}
+
+// set a list property found at row, like an array. The value has one item per row, like lists in conf files
+func (fr *formatReader) list(sectName, key string, items []string, row int) error {
+	return fr.setProp(sectName, key, strings.Join(items, "\n"), items, row)
+}
+
+// items are nil, unless the property is a list
+func (fr *formatReader) setProp(sectName, key, value string, items []string, row int) error {
+	origin := &Origin{
+		FileName:     fr.fileName,
+		RowNumber:    row,
+		IncludedFrom: fr.includedFrom,
+	}
+	if fr.ctx == nil {
+		fr.conf.setProp(sectName, key, value, origin)
+		fr.conf.setListItems(sectName, key, items)
+		return nil
+	}
+	err := fr.conf.setPropByPolicy(fr.ctx, fr.logger, sectName, key, value, items, origin)
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return err
+	}
+	pe.FileName = fr.fileName
+	pe.Line = row
+	pe.IncludedFrom = fr.includedFrom
+	if fr.ctx.Lenient {
+		fr.conf.parseErrors = append(fr.conf.parseErrors, pe)
+		return nil
+	}
+	return pe
+}
+
+// create an error for row
+func (fr *formatReader) errorAt(row int, format string, args ...any) error {
+	pe := newRowError(KindSyntax, -1, format, args...)
+	pe.FileName = fr.fileName
+	pe.Line = row
+	pe.IncludedFrom = fr.includedFrom
+	return pe
+}
+
+// read a file in another format, included from a conf file or given to NewConfigFromFile
+func readFormat(ctx *confContext.Context, load formatLoader, r io.Reader, absFilename string, conf *Config, logger *Logger) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	conf.filesUsed = append(conf.filesUsed, absFilename)
+	logEvent(logger, slog.LevelDebug, "reading file", slog.String("file", absFilename))
+	fr := &formatReader{conf: conf, fileName: absFilename, currSect: ctx.RunTime.Params[confContext.CurrSect], ctx: ctx, logger: logger}
+	if len(ctx.Stack) > 0 {
+		fr.includedFrom = append([]string(nil), ctx.Stack...)
+	}
+	// the file is on the stack while it is read, like conf files
+	ctx.Stack.Push(absFilename)
+	defer ctx.Stack.Pop()
+	return load(fr, data)
+}
+
+// read config from r with load, without includes
+func newConfigFromFormat(load formatLoader, r io.Reader, fileName, sectName string) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	conf := NewConfig()
+	conf.filesUsed = append(conf.filesUsed, fileName)
+	if err := load(&formatReader{conf: conf, fileName: fileName, currSect: sectName}, data); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+// Read config from yaml. Top level maps become sects and their scalars props. Nested maps become sects
+// named like parent.child, and lists of scalars list props, see Prop.Strings. Top level scalars end up in the sect
+// named "". fileName is only used in origins and errors
+func NewConfigFromYAML(r io.Reader, fileName string) (*Config, error) {
+	return newConfigFromFormat(readYAML, r, fileName, "")
+}
+
+// Read a java .properties file into the sect named sectName
+func NewConfigFromProperties(r io.Reader, fileName, sectName string) (*Config, error) {
+	return newConfigFromFormat(readProperties, r, fileName, sectName)
+}
+
+// Read a .env file into the sect named sectName
+func NewConfigFromDotenv(r io.Reader, fileName, sectName string) (*Config, error) {
+	return newConfigFromFormat(readDotenv, r, fileName, sectName)
+}
+
+// Read config from toml. Tables become sects, like [server.tls], and keys props. Keys before the first
+// table end up in the sect named "". Arrays of scalars become list props, see Prop.Strings. Strings
+// must be quoted. fileName is only used in origins and errors
+func NewConfigFromTOML(r io.Reader, fileName string) (*Config, error) {
+	return newConfigFromFormat(readTOML, r, fileName, "")
+}
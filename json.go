@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MarshalJSON writes sects as objects with string values, like {"sect": {"key": "value"}}, and list props
+// as arrays of strings. Sects are written in sectNames order and props in the order they were set
+func (conf *Config) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteRune('{')
+	for i, sectName := range conf.writeOrder() {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		if err := writeJSONString(&buf, sectName); err != nil {
+			return nil, err
+		}
+		buf.WriteString(":{")
+		props := conf.sects[sectName]
+		for j, key := range conf.propOrder(sectName) {
+			if j > 0 {
+				buf.WriteRune(',')
+			}
+			if err := writeJSONString(&buf, key); err != nil {
+				return nil, err
+			}
+			buf.WriteRune(':')
+			if items, isList := conf.lists[sectName][key]; isList {
+				b, err := json.Marshal(items)
+				if err != nil {
+					return nil, err
+				}
+				buf.Write(b)
+				continue
+			}
+			if err := writeJSONString(&buf, props[key]); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteRune('}')
+	}
+	buf.WriteRune('}')
+	return buf.Bytes(), nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// UnmarshalJSON replaces all sects in conf with the ones in data. See NewConfigFromJSON for the format
+func (conf *Config) UnmarshalJSON(data []byte) error {
+	*conf = *NewConfig()
+	return readJSON(&formatReader{conf: conf}, data)
+}
+
+// Read config from json, like {"sect": {"key": "value"}}. Sects keep their order. Numbers and booleans
+// are stored as written, null as an empty string and arrays of those as list props, see Prop.Strings.
+// fileName is only used in origins and errors
+func NewConfigFromJSON(r io.Reader, fileName string) (*Config, error) {
+	return newConfigFromFormat(readJSON, r, fileName, "")
+}
+
+func readJSON(fr *formatReader, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	fail := func(err error) error {
+		return fr.errorAt(lineAt(data, dec.InputOffset()), "%w", err)
+	}
+	if err := expectDelim(dec, '{'); err != nil {
+		return fail(err)
+	}
+	for dec.More() {
+		sectName, err := expectString(dec)
+		if err != nil {
+			return fail(err)
+		}
+		if err := expectDelim(dec, '{'); err != nil {
+			return fail(fmt.Errorf("sect %v: %w", sectName, err))
+		}
+		fr.sect(sectName)
+		for dec.More() {
+			key, err := expectString(dec)
+			if err != nil {
+				return fail(err)
+			}
+			row := lineAt(data, dec.InputOffset())
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return fail(err)
+			}
+			if array, isArray := v.([]any); isArray {
+				items := make([]string, len(array))
+				for i := range array {
+					if items[i], err = jsonValue(array[i]); err != nil {
+						return fail(fmt.Errorf("[%v] %v: %w", sectName, key, err))
+					}
+				}
+				if err := fr.list(sectName, key, items, row); err != nil {
+					return err
+				}
+				continue
+			}
+			value, err := jsonValue(v)
+			if err != nil {
+				return fail(fmt.Errorf("[%v] %v: %w", sectName, key, err))
+			}
+			if err := fr.prop(sectName, key, value, row); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return fail(err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return fail(err)
+	}
+	// nothing but white space may follow
+	if _, err := dec.Token(); err != io.EOF {
+		return fail(fmt.Errorf("unexpected data after the closing }"))
+	}
+	return nil
+}
+
+// add an empty sect, if it doesn't exist
+func (conf *Config) addSect(sectName string) {
+	if _, exists := conf.sects[sectName]; !exists {
+		conf.sects[sectName] = make(map[string]string)
+	}
+	if !conf.hasSectName(sectName) {
+		conf.sectNames = append(conf.sectNames, sectName)
+	}
+}
+
+// convert a decoded json scalar to a property value
+func jsonValue(v any) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		if val {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+func expectString(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if s, ok := tok.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("expected name, got %v", tok)
+}
+
+// row number of offset in data, starting at 1
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// reads a java .properties file into the current sect. Lines starting with # or ! are comments, and a
+// line ending with an odd number of backslashes continues on the next line, without its leading white
+// space. The key ends at the first unescaped =, : or white space
+func readProperties(fr *formatReader, data []byte) error {
+	fr.sect(fr.currSect)
+	rows := splitLines(data)
+	for i := 0; i < len(rows); i++ {
+		row := i + 1
+		line := strings.TrimLeft(rows[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// join continuation lines
+		for endsWithEscape(line) && i+1 < len(rows) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(rows[i], " \t\f")
+		}
+		if endsWithEscape(line) {
+			line = line[:len(line)-1]
+		}
+		key, value, err := splitPropertiesLine(line)
+		if err != nil {
+			return fr.errorAt(row, "%v", err)
+		}
+		if err := fr.prop(fr.currSect, key, value, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// split a logical .properties line into unescaped key and value
+func splitPropertiesLine(line string) (string, string, error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) != -1 {
+			end = i
+			break
+		}
+	}
+	key, err := unescapeProperties(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	// the separator is white space, optionally around one = or :
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	value, err := unescapeProperties(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// handle \t, \n, \r, \f and \uXXXX. Other escaped characters stand for themselves
+func unescapeProperties(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", strconv.ErrSyntax
+			}
+			code, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(rune(code))
+			i += 4
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// check if line ends with an odd number of backslashes
+func endsWithEscape(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// reads a .env file into the current sect. Rows are KEY=VALUE, optionally starting with export.
+// Unquoted values are trimmed and may end with a # comment. Single quoted values are taken as
+// written, and double quoted values may hold \n, \r, \t, \", \\ and \$. Quoted values may span
+// several rows. Variables like $HOME are not expanded
+func readDotenv(fr *formatReader, data []byte) error {
+	fr.sect(fr.currSect)
+	rows := splitLines(data)
+	for i := 0; i < len(rows); i++ {
+		row := i + 1
+		line := strings.TrimSpace(rows[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return fr.errorAt(row, "expected KEY=VALUE, got %v", rows[i])
+		}
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if err := fr.prop(fr.currSect, key, stripDotenvComment(value), row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// a quoted value ends at the closing quote, which may be on a later row
+		quote := value[0]
+		text := value[1:]
+		end := closingDotenvQuote(text, quote)
+		for end == -1 && i+1 < len(rows) {
+			i++
+			text += "\n" + rows[i]
+			end = closingDotenvQuote(text, quote)
+		}
+		if end == -1 {
+			return fr.errorAt(row, "unterminated value for %v", key)
+		}
+		if rest := strings.TrimSpace(text[end+1:]); rest != "" && rest[0] != '#' {
+			return fr.errorAt(i+1, "unexpected text after value for %v", key)
+		}
+		value = text[:end]
+		if quote == '"' {
+			value = unescapeDotenv(value)
+		}
+		if err := fr.prop(fr.currSect, key, value, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove a comment, which starts with # after white space
+func stripDotenvComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// index of the closing quote in text, or -1. Double quotes may be escaped
+func closingDotenvQuote(text string, quote byte) int {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// handle the escapes of double quoted values. Other backslashes are kept
+func unescapeDotenv(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\\', '$':
+			sb.WriteByte(s[i+1])
+		default:
+			sb.WriteByte('\\')
+			continue
+		}
+		i++
+	}
+	return sb.String()
+}
+
+// split data into rows, without line endings
+func splitLines(data []byte) []string {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// loggers that can write structured events, like the one from NewSlogLogger
+type attrLogger interface {
+	LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// write a trace event, if there is a logger. Loggers without support for attributes get them appended
+// to the message, like "entering sect file=app.conf line=3 section=db"
+func logEvent(logger *Logger, level slog.Level, msg string, attrs ...slog.Attr) {
+	if logger == nil || *logger == nil {
+		return
+	}
+	if al, ok := (*logger).(attrLogger); ok {
+		al.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for _, attr := range attrs {
+		sb.WriteRune(' ')
+		sb.WriteString(attr.String())
+	}
+	(*logger).Debug(sb.String())
+}
+
+// file and line attributes for the row being parsed
+func rowAttrs(frd *fileRowData, attrs ...slog.Attr) []slog.Attr {
+	return append([]slog.Attr{slog.String("file", frd.fileName), slog.Int("line", frd.rowNumber)}, attrs...)
+}
+
+// file and line attributes for origin
+func originAttrs(origin *Origin, attrs ...slog.Attr) []slog.Attr {
+	if origin == nil {
+		return attrs
+	}
+	if origin.EnvVar != "" {
+		return append([]slog.Attr{slog.String("env", origin.EnvVar)}, attrs...)
+	}
+	if origin.Flag != "" {
+		return append([]slog.Attr{slog.String("flag", origin.Flag)}, attrs...)
+	}
+	return append([]slog.Attr{slog.String("file", origin.FileName), slog.Int("line", origin.RowNumber)}, attrs...)
+}
+
+// Use logger for events after parsing, like validation warnings. Parsing sets the logger given to the constructor
+func (conf *Config) SetLogger(logger *Logger) {
+	conf.logger = logger
+}
+
+// Logger writing trace events to l. Parse events are written at debug level, with attributes like
+// file, line, section, key and claim
+func NewSlogLogger(l *slog.Logger) *Logger {
+	var logger Logger = &slogLogger{l: l}
+	return &logger
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (sl *slogLogger) Debug(args ...any) {
+	sl.l.Log(context.Background(), slog.LevelDebug, fmt.Sprint(args...))
+}
+
+func (sl *slogLogger) Debugf(format string, args ...any) {
+	if sl.l.Enabled(context.Background(), slog.LevelDebug) {
+		sl.l.Log(context.Background(), slog.LevelDebug, fmt.Sprintf(format, args...))
+	}
+}
+
+func (sl *slogLogger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	sl.l.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -1,20 +1,27 @@
 package config
 
-import (
-	"fmt"
-	"strings"
-)
+import "strings"
 
 type macro struct {
 	parameters map[string]string
 	paramOrder []string
 	properties map[string]string
+	// properties in the order they were defined
+	propOrder []string
+	origins   map[string]*Origin
+	// properties with list values, like key = [a, b], that are split when the macro is used
+	lists map[string]bool
+	// where the macro was defined
+	definedAt *Origin
+	used      bool
 }
 
 func NewMacro(params *string) *macro {
 	parameters := strings.Split(*params, string(rune(0)))
 	macro := macro{
 		properties: make(map[string]string),
+		origins:    make(map[string]*Origin),
+		lists:      make(map[string]bool),
 		parameters: make(map[string]string, len(parameters)),
 		paramOrder: parameters,
 	}
@@ -24,19 +31,36 @@ func NewMacro(params *string) *macro {
 	return &macro
 }
 
-func (m *macro) SetParamValues(paramValues *string, numParams int, sects *map[string]map[string]string, currSect string) error {
+// set property, remembering the order of new ones
+func (m *macro) setProperty(key, value string) {
+	if _, exists := m.properties[key]; !exists {
+		m.propOrder = append(m.propOrder, key)
+	}
+	m.properties[key] = value
+}
+
+// remember if the value of a property is a list
+func (m *macro) setList(key string, isList bool) {
+	if isList {
+		m.lists[key] = true
+	} else {
+		delete(m.lists, key)
+	}
+}
+
+func (m *macro) SetParamValues(paramValues *string, numParams int, conf *Config, currSect string) error {
 	parameterValues := strings.Split(*paramValues, string(rune(0)))
 	// there must be same num of params and values
 	if len(parameterValues) != numParams {
 		// this could be a parameter less call. Check if parameter values exists as properties
 		for paramName := range m.parameters {
-			prop, exists := (*sects)[currSect][paramName]
+			prop, exists := conf.sects[currSect][paramName]
 			if !exists {
-				return fmt.Errorf("not same num of params and values: num params = %v and num values = %v", numParams, len(m.paramOrder))
+				return newRowError(KindMacro, -1, "not same num of params and values: num params = %v and num values = %v", numParams, len(m.paramOrder))
 			}
 			m.parameters[paramName] = prop
 			// remove property, since it was not a "real property"
-			delete((*sects)[currSect], paramName)
+			conf.deleteProperty(currSect, paramName)
 		}
 		return nil
 	}
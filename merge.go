@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strconv"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// MergePolicy tells what happens when a later layer sets a property an earlier layer already has
+type MergePolicy int8
+
+const (
+	// the last layer setting the property wins
+	LastWins MergePolicy = iota
+	// a different value in a later layer is an error
+	ErrorOnConflict
+)
+
+// ConflictError is a property given different values by two layers, with the ErrorOnConflict policy
+type ConflictError struct {
+	Sect string
+	Prop string
+	// the layer trying to set the property, and its origin
+	Layer  string
+	Origin *Origin
+	// the layer that set the property before, and its origin
+	PreviousLayer  string
+	PreviousOrigin *Origin
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("[%v] %v: layer %v conflicts with layer %v", e.Sect, e.Prop, e.Layer, e.PreviousLayer)
+}
+
+// Merge layers into a new config, where later layers override earlier ones. Sects keep the order they
+// first appear in. Origins of the merged props tell which layer won, named by its conf file, or by
+// its position if it has none. Nil layers are skipped
+func Merge(layers ...*Config) *Config {
+	merged := NewConfig()
+	for i, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		name := "layer " + strconv.Itoa(i)
+		if len(layer.filesUsed) > 0 {
+			name = layer.ConfFileName()
+		}
+		// LastWins never conflicts
+		merged.mergeLayer(name, layer, func(string, string) MergePolicy { return LastWins })
+	}
+	return merged
+}
+
+// add props in layer to conf, remembering the layer in their origins
+func (conf *Config) mergeLayer(name string, layer *Config, policy func(sectName, propName string) MergePolicy) error {
+	conf.filesUsed = append(conf.filesUsed, layer.filesUsed...)
+	conf.filesMissing = append(conf.filesMissing, layer.filesMissing...)
+	for _, sectName := range layer.sectNames {
+		conf.addSect(sectName)
+		for _, propName := range layer.propOrder(sectName) {
+			value := layer.sects[sectName][propName]
+			origin := &Origin{}
+			if o := layer.getOrigin(sectName, propName); o != nil {
+				*origin = *o
+			}
+			origin.Layer = name
+			if previous, exists := conf.sects[sectName][propName]; exists && previous != value && policy(sectName, propName) == ErrorOnConflict {
+				prevOrigin := conf.getOrigin(sectName, propName)
+				conflict := &ConflictError{Sect: sectName, Prop: propName, Layer: name, Origin: origin, PreviousOrigin: prevOrigin}
+				if prevOrigin != nil {
+					conflict.PreviousLayer = prevOrigin.Layer
+				}
+				return conflict
+			}
+			conf.setProp(sectName, propName, value, origin)
+			if values, exists := layer.values[sectName][propName]; exists {
+				if _, exists := conf.values[sectName]; !exists {
+					conf.values[sectName] = make(map[string][]string)
+				}
+				conf.values[sectName][propName] = append([]string(nil), values...)
+			}
+			if items, isList := layer.lists[sectName][propName]; isList {
+				conf.setListItems(sectName, propName, append([]string{}, items...))
+			}
+		}
+	}
+	return nil
+}
+
+// Loader composes a config from layers, like defaults, a system file, a user file, the environment and
+// flags. Layers are merged in the order they are added, so later layers take precedence
+type Loader struct {
+	ctx    *confContext.Context
+	logger *Logger
+	layers []loaderLayer
+	// policies by sect and prop, where prop "" means the whole sect
+	policies      map[[2]string]MergePolicy
+	defaultPolicy MergePolicy
+}
+
+// one layer, loaded when the loader is. merged holds the layers before it
+type loaderLayer struct {
+	name string
+	load func(merged *Config) (*Config, error)
+}
+
+// Create a loader. Files are parsed with copies of ctx, which may be nil
+func NewLoader(ctx *confContext.Context, logger *Logger) *Loader {
+	if ctx == nil {
+		ctx = confContext.NewContext(nil)
+	}
+	return &Loader{
+		ctx:      ctx,
+		logger:   logger,
+		layers:   make([]loaderLayer, 0),
+		policies: make(map[[2]string]MergePolicy),
+	}
+}
+
+// Add conf as a layer, like defaults built in code
+func (l *Loader) Layer(name string, conf *Config) *Loader {
+	l.layers = append(l.layers, loaderLayer{name: name, load: func(*Config) (*Config, error) {
+		return conf, nil
+	}})
+	return l
+}
+
+// Add a conf file as a layer. Loading fails if the file doesn't exist
+func (l *Loader) File(name, fileName string) *Loader {
+	return l.file(name, fileName, false)
+}
+
+// Add a conf file as a layer, that is skipped if the file doesn't exist
+func (l *Loader) OptionalFile(name, fileName string) *Loader {
+	return l.file(name, fileName, true)
+}
+
+func (l *Loader) file(name, fileName string, optional bool) *Loader {
+	l.layers = append(l.layers, loaderLayer{name: name, load: func(*Config) (*Config, error) {
+		// environment overrides are a layer of their own
+		ctx := l.ctx.Copy()
+		ctx.EnvOverridePrefix = ""
+		conf, err := NewConfigFromFile(ctx, fileName, l.logger)
+		// only the layer file itself may be missing, not the files it includes
+		if err != nil && optional && errors.Is(err, fs.ErrNotExist) && len(conf.filesUsed) == 0 {
+			// remember the file, since it may show up later
+			absFilename, absErr := ctx.AbsPath(fileName)
+			if absErr != nil {
+				return nil, absErr
+			}
+			conf = NewConfig()
+			conf.filesMissing = append(conf.filesMissing, absFilename)
+			return conf, nil
+		}
+		return conf, err
+	}})
+	return l
+}
+
+// Add environment variables named <prefix><separator>SECT<separator>PROP as a layer. Sect and prop
+// names are matched against the layers before it, see ApplyEnvOverrides
+func (l *Loader) Env(name, prefix string) *Loader {
+	l.layers = append(l.layers, loaderLayer{name: name, load: func(merged *Config) (*Config, error) {
+		return merged.envLayer(l.ctx, prefix), nil
+	}})
+	return l
+}
+
+// Add properties given on the command line as a layer
+func (l *Loader) Flags(name string, f *Flags) *Loader {
+	l.layers = append(l.layers, loaderLayer{name: name, load: func(*Config) (*Config, error) {
+		conf := NewConfig()
+		f.Apply(conf)
+		return conf, nil
+	}})
+	return l
+}
+
+// Set the policy for a prop, or for all props in a sect if propName is empty
+func (l *Loader) Policy(sectName, propName string, policy MergePolicy) *Loader {
+	l.policies[[2]string{sectName, propName}] = policy
+	return l
+}
+
+// Set the policy for props without a policy of their own. The default is LastWins
+func (l *Loader) DefaultPolicy(policy MergePolicy) *Loader {
+	l.defaultPolicy = policy
+	return l
+}
+
+// find the policy for a prop
+func (l *Loader) policy(sectName, propName string) MergePolicy {
+	if policy, exists := l.policies[[2]string{sectName, propName}]; exists {
+		return policy
+	}
+	if policy, exists := l.policies[[2]string{sectName, ""}]; exists {
+		return policy
+	}
+	return l.defaultPolicy
+}
+
+// Load all layers and merge them. The origin of each prop names the layer that won
+func (l *Loader) Load() (*Config, error) {
+	merged := NewConfig()
+	for _, layer := range l.layers {
+		conf, err := layer.load(merged)
+		if err != nil {
+			return merged, fmt.Errorf("layer %v: %w", layer.name, err)
+		}
+		if err := merged.mergeLayer(layer.name, conf, l.policy); err != nil {
+			return merged, err
+		}
+		logEvent(l.logger, slog.LevelDebug, "layer merged", slog.String("layer", layer.name), slog.Int("sects", len(conf.sectNames)))
+	}
+	return merged, nil
+}
@@ -2,8 +2,13 @@ package config
 
 import "fmt"
 
+// Return a copy of all sects and their props. Changing it doesn't change conf
 func (conf *Config) GetSects() map[string]map[string]string {
-	return conf.sects
+	sects := make(map[string]map[string]string, len(conf.sects))
+	for sectName, props := range conf.sects {
+		sects[sectName] = copyProps(props)
+	}
+	return sects
 }
 
 // Return a slice of all sectname
@@ -18,10 +23,19 @@ func (conf *Config) GetAllSectNames() []string {
 	return sectNames
 }
 
+// Return a copy of the props in sect. Changing it doesn't change conf
 func (conf *Config) GetProperties(sect string) (map[string]string, error) {
 	props, exists := conf.sects[sect]
 	if !exists {
 		return nil, fmt.Errorf("section %s not found", sect)
 	}
-	return props, nil
+	return copyProps(props), nil
+}
+
+func copyProps(props map[string]string) map[string]string {
+	c := make(map[string]string, len(props))
+	for k, v := range props {
+		c[k] = v
+	}
+	return c
 }
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errReadOnly is returned when changing a sect from a snapshot
+var errReadOnly = errors.New("sect belongs to a snapshot and can not be changed")
+
+// Enforce schema on all later changes made with SetProp, DeleteProp, RenameSect and DeleteSect.
+// conf must be valid already, or the schema is not set and the violations returned. A nil schema
+// stops enforcing
+func (conf *Config) SetSchema(schema *Schema) error {
+	if schema != nil {
+		if err := conf.Validate(schema); err != nil {
+			return err
+		}
+	}
+	conf.schema = schema
+	return nil
+}
+
+// Add an empty sect last, if it doesn't exist
+func (conf *Config) AddSect(sectName string) error {
+	if _, exists := conf.sects[sectName]; exists {
+		return nil
+	}
+	if err := conf.checkSect(sectName); err != nil {
+		return err
+	}
+	conf.addSect(sectName)
+	return nil
+}
+
+// Set a property, adding the sect last if it doesn't exist
+func (conf *Config) SetProp(sectName, propName, value string) error {
+	if err := conf.checkSect(sectName); err != nil {
+		return err
+	}
+	if err := conf.checkProp(sectName, propName, value); err != nil {
+		return err
+	}
+	conf.addSect(sectName)
+	conf.setProp(sectName, propName, value, nil)
+	return nil
+}
+
+// Delete a property
+func (conf *Config) DeleteProp(sectName, propName string) error {
+	if _, exists := conf.sects[sectName][propName]; !exists {
+		return fmt.Errorf("property %v not found in section %v", propName, sectName)
+	}
+	if conf.schema != nil {
+		if ps := conf.schemaProp(sectName, propName); ps != nil && ps.required {
+			return &ValidationError{Sect: sectName, Prop: propName, Msg: "required property can not be deleted"}
+		}
+	}
+	conf.deleteProperty(sectName, propName)
+	return nil
+}
+
+// Rename a sect, keeping its position among the sects
+func (conf *Config) RenameSect(oldName, newName string) error {
+	props, exists := conf.sects[oldName]
+	if !exists && !conf.hasSectName(oldName) {
+		return fmt.Errorf("section %s not found", oldName)
+	}
+	if _, exists := conf.sects[newName]; exists || conf.hasSectName(newName) {
+		return fmt.Errorf("section %s already exists", newName)
+	}
+	if err := conf.checkDeleteSect(oldName); err != nil {
+		return err
+	}
+	if err := conf.checkSect(newName); err != nil {
+		return err
+	}
+	for _, propName := range conf.propOrder(oldName) {
+		if err := conf.checkProp(newName, propName, props[propName]); err != nil {
+			return err
+		}
+	}
+
+	for i := range conf.sectNames {
+		if conf.sectNames[i] == oldName {
+			conf.sectNames[i] = newName
+		}
+	}
+	if exists {
+		conf.sects[newName] = props
+		delete(conf.sects, oldName)
+	}
+	if origins, exists := conf.origins[oldName]; exists {
+		conf.origins[newName] = origins
+		delete(conf.origins, oldName)
+	}
+	if propNames, exists := conf.propNames[oldName]; exists {
+		conf.propNames[newName] = propNames
+		delete(conf.propNames, oldName)
+	}
+	if values, exists := conf.values[oldName]; exists {
+		conf.values[newName] = values
+		delete(conf.values, oldName)
+	}
+	if lists, exists := conf.lists[oldName]; exists {
+		conf.lists[newName] = lists
+		delete(conf.lists, oldName)
+	}
+	return nil
+}
+
+// Delete a sect and all its props
+func (conf *Config) DeleteSect(sectName string) error {
+	if _, exists := conf.sects[sectName]; !exists && !conf.hasSectName(sectName) {
+		return fmt.Errorf("section %s not found", sectName)
+	}
+	if err := conf.checkDeleteSect(sectName); err != nil {
+		return err
+	}
+	sectNames := make([]string, 0, len(conf.sectNames))
+	for _, name := range conf.sectNames {
+		if name != sectName {
+			sectNames = append(sectNames, name)
+		}
+	}
+	conf.sectNames = sectNames
+	delete(conf.sects, sectName)
+	delete(conf.origins, sectName)
+	delete(conf.propNames, sectName)
+	delete(conf.values, sectName)
+	delete(conf.lists, sectName)
+	return nil
+}
+
+// check that the schema, if any, allows the sect
+func (conf *Config) checkSect(sectName string) error {
+	if conf.schema == nil || conf.schema.allowUnknownSects || conf.schema.findSect(sectName) != nil {
+		return nil
+	}
+	return &ValidationError{Sect: sectName, Msg: "unknown sect"}
+}
+
+// check that the schema, if any, allows value for the prop
+func (conf *Config) checkProp(sectName, propName, value string) error {
+	if conf.schema == nil {
+		return nil
+	}
+	ss := conf.schema.findSect(sectName)
+	if ss == nil {
+		return nil
+	}
+	ps := ss.findProp(propName)
+	if ps == nil {
+		if ss.allowUnknown {
+			return nil
+		}
+		return &ValidationError{Sect: sectName, Prop: propName, Msg: "unknown property"}
+	}
+	if err := ps.check(value, nil); err != nil {
+		return &ValidationError{Sect: sectName, Prop: propName, Msg: err.Error()}
+	}
+	return nil
+}
+
+// check that the schema, if any, doesn't require the sect
+func (conf *Config) checkDeleteSect(sectName string) error {
+	if conf.schema == nil {
+		return nil
+	}
+	if ss := conf.schema.findSect(sectName); ss != nil && ss.required {
+		return &ValidationError{Sect: sectName, Msg: "required sect can not be deleted"}
+	}
+	return nil
+}
+
+// description of a prop in the schema, or nil
+func (conf *Config) schemaProp(sectName, propName string) *PropSchema {
+	if ss := conf.schema.findSect(sectName); ss != nil {
+		return ss.findProp(propName)
+	}
+	return nil
+}
+
+// Set a property, adding the sect if it doesn't exist. See Config.SetProp
+func (sect *Sect) SetProp(propName, value string) error {
+	if sect.readOnly {
+		return errReadOnly
+	}
+	if err := sect.conf.SetProp(sect.name, propName, value); err != nil {
+		return err
+	}
+	sect.Exists = true
+	return nil
+}
+
+// Delete a property. See Config.DeleteProp
+func (sect *Sect) DeleteProp(propName string) error {
+	if sect.readOnly {
+		return errReadOnly
+	}
+	return sect.conf.DeleteProp(sect.name, propName)
+}
+
+// Rename the sect, keeping its position. See Config.RenameSect
+func (sect *Sect) Rename(newName string) error {
+	if sect.readOnly {
+		return errReadOnly
+	}
+	if err := sect.conf.RenameSect(sect.name, newName); err != nil {
+		return err
+	}
+	sect.name = newName
+	return nil
+}
+
+// Delete the sect and all its props. See Config.DeleteSect
+func (sect *Sect) Delete() error {
+	if sect.readOnly {
+		return errReadOnly
+	}
+	if err := sect.conf.DeleteSect(sect.name); err != nil {
+		return err
+	}
+	sect.Exists = false
+	return nil
+}
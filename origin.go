@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// Origin tells where a property got its value
+type Origin struct {
+	FileName  string
+	RowNumber int
+	// files that included FileName, starting with the root conf file
+	IncludedFrom []string
+	// set if the property was produced by a [use macro] row
+	Macro *MacroOrigin
+	// set if the property was overridden by an environment variable
+	EnvVar string
+	// set if the property was given on the command line
+	Flag string
+	// set if the property won a merge of layers, naming the layer
+	Layer string
+}
+
+// MacroOrigin tells which macro produced a property, and where the macro defined it
+type MacroOrigin struct {
+	Name      string
+	FileName  string
+	RowNumber int
+}
+
+// create origin for the row currently being processed
+func newOrigin(ctx *confContext.Context, data *fileRowData) *Origin {
+	origin := &Origin{
+		FileName:  data.fileName,
+		RowNumber: data.rowNumber,
+	}
+	// the top of the stack is the current file
+	if len(ctx.Stack) > 1 {
+		origin.IncludedFrom = append([]string(nil), ctx.Stack[:len(ctx.Stack)-1]...)
+	}
+	return origin
+}
+
+// copy of origin, produced by macro
+func (o *Origin) withMacro(name string, definedAt *Origin) *Origin {
+	origin := *o
+	origin.Macro = &MacroOrigin{Name: name}
+	if definedAt != nil {
+		origin.Macro.FileName = definedAt.FileName
+		origin.Macro.RowNumber = definedAt.RowNumber
+	}
+	return &origin
+}
+
+func (o *Origin) String() string {
+	if o.Layer == "" {
+		return o.source()
+	}
+	if o.FileName == "" && o.EnvVar == "" && o.Flag == "" {
+		return "layer " + o.Layer
+	}
+	return o.source() + " (layer " + o.Layer + ")"
+}
+
+// where the value came from, without layer
+func (o *Origin) source() string {
+	if o.EnvVar != "" {
+		return "environment variable " + o.EnvVar
+	}
+	if o.Flag != "" {
+		return "command line flag " + o.Flag
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%v:%v", o.FileName, o.RowNumber))
+	if o.Macro != nil {
+		sb.WriteString(fmt.Sprintf(" (macro %v", o.Macro.Name))
+		if o.Macro.FileName != "" {
+			sb.WriteString(fmt.Sprintf(" defined at %v:%v", o.Macro.FileName, o.Macro.RowNumber))
+		}
+		sb.WriteRune(')')
+	}
+	for i := len(o.IncludedFrom) - 1; i >= 0; i-- {
+		sb.WriteString(" included from ")
+		sb.WriteString(o.IncludedFrom[i])
+	}
+	return sb.String()
+}
+
+// remember where a property in a sect got its value
+func (conf *Config) setOrigin(sectName, key string, origin *Origin) {
+	if props, exists := conf.origins[sectName]; exists {
+		props[key] = origin
+	} else {
+		conf.origins[sectName] = map[string]*Origin{key: origin}
+	}
+}
+
+// get origin for property. Nil if unknown
+func (conf *Config) getOrigin(sectName, key string) *Origin {
+	return conf.origins[sectName][key]
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// ErrorKind tells what kind of problem a ParseError describes
+type ErrorKind int8
+
+const (
+	KindSyntax ErrorKind = iota
+	KindConstant
+	KindClaims
+	KindMacro
+	KindInclude
+	KindEnv
+	KindDuplicate
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindSyntax:
+		return "syntax"
+	case KindConstant:
+		return "constant"
+	case KindClaims:
+		return "claims"
+	case KindMacro:
+		return "macro"
+	case KindInclude:
+		return "include"
+	case KindEnv:
+		return "env"
+	case KindDuplicate:
+		return "duplicate"
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int8(k))
+}
+
+// ParseError describes a problem found while parsing a row
+type ParseError struct {
+	Kind     ErrorKind
+	FileName string
+	Line     int
+	// rune position on the row, starting at 1. 0 if unknown
+	Column int
+	// files that included FileName, starting with the root conf file
+	IncludedFrom []string
+	Err          error
+}
+
+// create error for the row being parsed. pos is the rune position on the row, or -1 if unknown.
+// File, line and include stack are added by unMarshall
+func newRowError(kind ErrorKind, pos int, format string, args ...any) *ParseError {
+	return &ParseError{
+		Kind:   kind,
+		Column: pos + 1,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	if e.FileName != "" {
+		sb.WriteString(fmt.Sprintf("%v:%v:", e.FileName, e.Line))
+		if e.Column > 0 {
+			sb.WriteString(fmt.Sprintf("%v:", e.Column))
+		}
+		sb.WriteRune(' ')
+	}
+	sb.WriteString(e.Kind.String())
+	sb.WriteString(" error: ")
+	sb.WriteString(e.Err.Error())
+	for i := len(e.IncludedFrom) - 1; i >= 0; i-- {
+		sb.WriteString(" included from ")
+		sb.WriteString(e.IncludedFrom[i])
+	}
+	return sb.String()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors holds all errors found while parsing in lenient mode
+type ParseErrors []*ParseError
+
+func (errs ParseErrors) Error() string {
+	var sb strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			sb.WriteRune('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// position err at the current row. In lenient mode the error is remembered and nil is returned
+func (conf *Config) handleRowError(ctx *confContext.Context, frd *fileRowData, err error) error {
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		pe = &ParseError{Kind: KindSyntax, Err: err}
+	}
+	// errors from included files are already positioned
+	if pe.FileName == "" {
+		pe.FileName = frd.fileName
+		pe.Line = frd.rowNumber
+		// the top of the stack is the current file
+		if len(ctx.Stack) > 1 {
+			pe.IncludedFrom = append([]string(nil), ctx.Stack[:len(ctx.Stack)-1]...)
+		}
+	}
+	if !ctx.Lenient {
+		return pe
+	}
+	conf.parseErrors = append(conf.parseErrors, pe)
+	return nil
+}
+
+// errors from macro bodies have columns within the macro, not on the [use] row, so the column is dropped
+func withoutColumn(err error) error {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		pe.Column = 0
+	}
+	return err
+}
+
+// all errors found in lenient mode, or nil
+func (conf *Config) collectedErrors() error {
+	if len(conf.parseErrors) > 0 {
+		return conf.parseErrors
+	}
+	return nil
+}
@@ -2,13 +2,20 @@ package config
 
 import (
 	"fmt"
-	"strconv"
+	"net"
+	"net/url"
+	"time"
 )
 
 type Prop struct {
 	name   string
 	value  string
 	exists bool
+	origin *Origin
+	// all values, if the property was set more than once with the DuplicateAccumulate policy
+	values []string
+	// items, if the property is a list
+	items []string
 }
 
 func newProp(name string, value string, exists bool) *Prop {
@@ -20,6 +27,23 @@ func newProp(name string, value string, exists bool) *Prop {
 	return &prop
 }
 
+// Name of the property
+func (p *Prop) Name() string {
+	return p.name
+}
+
+// All values of a property set on several rows with the DuplicateAccumulate policy, in order.
+// Otherwise just the value
+func (p *Prop) Values() ([]string, error) {
+	if !p.exists {
+		return nil, fmt.Errorf("property %v does not exists", p.name)
+	}
+	if p.values != nil {
+		return append([]string(nil), p.values...), nil
+	}
+	return []string{p.value}, nil
+}
+
 func (p *Prop) Value() (string, error) {
 	if !p.exists {
 		err := fmt.Errorf("property %v does not exists", p.name)
@@ -28,21 +52,243 @@ func (p *Prop) Value() (string, error) {
 	return p.value, nil
 }
 
+// Where the property got its value. Nil if the property doesn't exists
+func (p *Prop) Origin() *Origin {
+	return p.origin
+}
+
 func (p *Prop) ValueOrDefault(def any) (any, error) {
 	if !p.exists {
 		return def, nil
 	}
-	propValue := p.value
-
-	if _, isString := def.(string); isString {
-		return propValue, nil
-	}
 
-	if _, isInt := def.(int); isInt {
-		val, err := strconv.Atoi(propValue)
-		return val, err
+	switch def.(type) {
+	case string:
+		return p.value, nil
+	case int:
+		return p.Int()
+	case int64:
+		return p.Int64()
+	case uint:
+		return p.Uint()
+	case bool:
+		return p.Bool()
+	case float64:
+		return p.Float64()
+	case time.Duration:
+		return p.Duration()
+	case *url.URL:
+		return p.URL()
+	case net.IP:
+		return p.IP()
+	case *net.IPNet:
+		return p.CIDR()
+	case []string:
+		return p.Strings()
+	case []int:
+		return p.Ints()
 	}
 
 	err := fmt.Errorf("datatype not implemented")
 	return def, err
 }
+
+// convert the value with parse. Returns an error if the property doesn't exists or can't be converted
+func propAs[T any](p *Prop, parse func(string) (T, error)) (T, error) {
+	var zero T
+	if !p.exists {
+		return zero, fmt.Errorf("property %v does not exists", p.name)
+	}
+	val, err := parse(p.value)
+	if err != nil {
+		return zero, fmt.Errorf("property %v: %w", p.name, err)
+	}
+	return val, nil
+}
+
+func (p *Prop) Bool() (bool, error) {
+	return propAs(p, parseBool)
+}
+
+func (p *Prop) BoolOrDefault(def bool) bool {
+	if val, err := p.Bool(); err == nil {
+		return val
+	}
+	return def
+}
+
+func (p *Prop) Int() (int, error) {
+	return propAs(p, func(s string) (int, error) {
+		i, err := parseInt(s, 0)
+		return int(i), err
+	})
+}
+
+func (p *Prop) IntOrDefault(def int) int {
+	if val, err := p.Int(); err == nil {
+		return val
+	}
+	return def
+}
+
+func (p *Prop) Int64() (int64, error) {
+	return propAs(p, func(s string) (int64, error) {
+		return parseInt(s, 64)
+	})
+}
+
+func (p *Prop) Int64OrDefault(def int64) int64 {
+	if val, err := p.Int64(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Int64 in the given base. Int64 and the other int methods always use base 10, so "010" is 10. With base 0,
+// the base is given by the prefix like in Go code, so "0x1f" is hex, "0o17" and "017" are octal and "0b11" is binary
+func (p *Prop) Int64Base(base int) (int64, error) {
+	return propAs(p, func(s string) (int64, error) {
+		return parseIntBase(s, base, 64)
+	})
+}
+
+func (p *Prop) Uint() (uint, error) {
+	return propAs(p, func(s string) (uint, error) {
+		u, err := parseUint(s, 0)
+		return uint(u), err
+	})
+}
+
+func (p *Prop) UintOrDefault(def uint) uint {
+	if val, err := p.Uint(); err == nil {
+		return val
+	}
+	return def
+}
+
+func (p *Prop) Float64() (float64, error) {
+	return propAs(p, func(s string) (float64, error) {
+		return parseFloat(s, 64)
+	})
+}
+
+func (p *Prop) Float64OrDefault(def float64) float64 {
+	if val, err := p.Float64(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Duration in time.ParseDuration format, like "1m30s"
+func (p *Prop) Duration() (time.Duration, error) {
+	return propAs(p, parseDuration)
+}
+
+func (p *Prop) DurationOrDefault(def time.Duration) time.Duration {
+	if val, err := p.Duration(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Time in given layout, like time.RFC3339
+func (p *Prop) Time(layout string) (time.Time, error) {
+	return propAs(p, func(s string) (time.Time, error) {
+		return parseTime(s, layout)
+	})
+}
+
+func (p *Prop) TimeOrDefault(layout string, def time.Time) time.Time {
+	if val, err := p.Time(layout); err == nil {
+		return val
+	}
+	return def
+}
+
+func (p *Prop) URL() (*url.URL, error) {
+	return propAs(p, parseURL)
+}
+
+func (p *Prop) URLOrDefault(def *url.URL) *url.URL {
+	if val, err := p.URL(); err == nil {
+		return val
+	}
+	return def
+}
+
+func (p *Prop) IP() (net.IP, error) {
+	return propAs(p, parseIP)
+}
+
+func (p *Prop) IPOrDefault(def net.IP) net.IP {
+	if val, err := p.IP(); err == nil {
+		return val
+	}
+	return def
+}
+
+// CIDR network, like "10.0.0.0/8"
+func (p *Prop) CIDR() (*net.IPNet, error) {
+	return propAs(p, parseCIDR)
+}
+
+func (p *Prop) CIDROrDefault(def *net.IPNet) *net.IPNet {
+	if val, err := p.CIDR(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Size in bytes, like "10MB" or "512KiB"
+func (p *Prop) ByteSize() (uint64, error) {
+	return propAs(p, parseByteSize)
+}
+
+func (p *Prop) ByteSizeOrDefault(def uint64) uint64 {
+	if val, err := p.ByteSize(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Items of a list property, like key = [a, "b,c"] or repeated key[] = a rows. Otherwise the values of a
+// multiline property, or a comma separated list if the value is on a single row
+func (p *Prop) Strings() ([]string, error) {
+	if p.exists && p.items != nil {
+		return append([]string{}, p.items...), nil
+	}
+	return propAs(p, func(s string) ([]string, error) {
+		return splitList(s), nil
+	})
+}
+
+func (p *Prop) StringsOrDefault(def []string) []string {
+	if val, err := p.Strings(); err == nil {
+		return val
+	}
+	return def
+}
+
+// Items of a list property as ints, see Strings
+func (p *Prop) Ints() ([]int, error) {
+	items, err := p.Strings()
+	if err != nil {
+		return nil, err
+	}
+	ints := make([]int, 0, len(items))
+	for _, item := range items {
+		i, err := parseInt(item, 0)
+		if err != nil {
+			return nil, fmt.Errorf("property %v: %w", p.name, err)
+		}
+		ints = append(ints, int(i))
+	}
+	return ints, nil
+}
+
+func (p *Prop) IntsOrDefault(def []int) []int {
+	if val, err := p.Ints(); err == nil {
+		return val
+	}
+	return def
+}
@@ -0,0 +1,62 @@
+package config
+
+// SkippedSect is a sect that was skipped, since its claims were not fulfilled
+type SkippedSect struct {
+	Name   string
+	Claims string
+	Origin *Origin
+}
+
+// DuplicateProp is a property set more than once in the same sect
+type DuplicateProp struct {
+	Sect   string
+	Prop   string
+	Origin *Origin
+	// where the property was set before
+	Previous *Origin
+}
+
+// UnusedMacro is a macro that was defined, but never used
+type UnusedMacro struct {
+	Name   string
+	Origin *Origin
+}
+
+// MissingInclude is an optional include of a file that did not exist
+type MissingInclude struct {
+	FileName string
+	Origin   *Origin
+}
+
+// things noticed while parsing, that are not errors
+type parseReport struct {
+	skippedSects    []SkippedSect
+	duplicateProps  []DuplicateProp
+	missingIncludes []MissingInclude
+}
+
+// Sects skipped because of claims, in the order they were found
+func (conf *Config) SkippedSects() []SkippedSect {
+	return conf.report.skippedSects
+}
+
+// Properties set more than once in the same sect, by rows in conf files
+func (conf *Config) DuplicateProps() []DuplicateProp {
+	return conf.report.duplicateProps
+}
+
+// Optional includes of files that did not exist
+func (conf *Config) MissingIncludes() []MissingInclude {
+	return conf.report.missingIncludes
+}
+
+// Macros that were defined but never used, sorted by name
+func (conf *Config) UnusedMacros() []UnusedMacro {
+	unused := make([]UnusedMacro, 0)
+	for _, name := range sortedKeys(conf.macros) {
+		if m := conf.macros[name]; !m.used {
+			unused = append(unused, UnusedMacro{Name: name, Origin: m.definedAt})
+		}
+	}
+	return unused
+}
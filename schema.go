@@ -0,0 +1,505 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// PropType is the expected type of a property value
+type PropType string
+
+const (
+	TypeString   PropType = "string"
+	TypeBool     PropType = "bool"
+	TypeInt      PropType = "int"
+	TypeUint     PropType = "uint"
+	TypeFloat    PropType = "float"
+	TypeDuration PropType = "duration"
+	TypeByteSize PropType = "bytesize"
+	TypeURL      PropType = "url"
+	TypeIP       PropType = "ip"
+	TypeCIDR     PropType = "cidr"
+	TypeList     PropType = "list"
+)
+
+func (t PropType) known() bool {
+	switch t {
+	case TypeString, TypeBool, TypeInt, TypeUint, TypeFloat, TypeDuration, TypeByteSize, TypeURL, TypeIP, TypeCIDR, TypeList:
+		return true
+	}
+	return false
+}
+
+// name of the sect with settings for the whole schema, in schema files
+const schemaSectName = "@schema"
+
+// Schema describes the expected sects and props of a config. Build it in go with NewSchema,
+// or read it from a schema file with LoadSchema
+type Schema struct {
+	sects             []*SectSchema
+	allowUnknownSects bool
+}
+
+// SectSchema describes one sect
+type SectSchema struct {
+	name         string
+	required     bool
+	allowUnknown bool
+	props        []*PropSchema
+}
+
+// PropSchema describes one property
+type PropSchema struct {
+	name     string
+	required bool
+	propType PropType
+	min      *float64
+	max      *float64
+	pattern  *regexp.Regexp
+	enum     []string
+}
+
+func NewSchema() *Schema {
+	return &Schema{sects: make([]*SectSchema, 0)}
+}
+
+// Allow sects not described by the schema
+func (s *Schema) AllowUnknownSects() *Schema {
+	s.allowUnknownSects = true
+	return s
+}
+
+// Get the description of sect name, creating it if needed
+func (s *Schema) Sect(name string) *SectSchema {
+	if ss := s.findSect(name); ss != nil {
+		return ss
+	}
+	ss := &SectSchema{name: name, props: make([]*PropSchema, 0)}
+	s.sects = append(s.sects, ss)
+	return ss
+}
+
+func (s *Schema) findSect(name string) *SectSchema {
+	for _, ss := range s.sects {
+		if ss.name == name {
+			return ss
+		}
+	}
+	return nil
+}
+
+// The sect must exist
+func (ss *SectSchema) Required() *SectSchema {
+	ss.required = true
+	return ss
+}
+
+// Allow props not described by the schema
+func (ss *SectSchema) AllowUnknown() *SectSchema {
+	ss.allowUnknown = true
+	return ss
+}
+
+// Get the description of prop name, creating it if needed. New props are strings
+func (ss *SectSchema) Prop(name string) *PropSchema {
+	if ps := ss.findProp(name); ps != nil {
+		return ps
+	}
+	ps := &PropSchema{name: name, propType: TypeString}
+	ss.props = append(ss.props, ps)
+	return ps
+}
+
+func (ss *SectSchema) findProp(name string) *PropSchema {
+	for _, ps := range ss.props {
+		if ps.name == name {
+			return ps
+		}
+	}
+	return nil
+}
+
+// The prop must exist
+func (ps *PropSchema) Required() *PropSchema {
+	ps.required = true
+	return ps
+}
+
+func (ps *PropSchema) Type(t PropType) *PropSchema {
+	ps.propType = t
+	return ps
+}
+
+// Lowest allowed value. Durations are compared in nanoseconds, strings by length and lists by number of items
+func (ps *PropSchema) Min(v float64) *PropSchema {
+	ps.min = &v
+	return ps
+}
+
+// Highest allowed value. Durations are compared in nanoseconds, strings by length and lists by number of items
+func (ps *PropSchema) Max(v float64) *PropSchema {
+	ps.max = &v
+	return ps
+}
+
+// The whole value must match re
+func (ps *PropSchema) Pattern(re *regexp.Regexp) *PropSchema {
+	ps.pattern = re
+	return ps
+}
+
+// The value must be one of values
+func (ps *PropSchema) Enum(values ...string) *PropSchema {
+	ps.enum = values
+	return ps
+}
+
+// Short description, like "int, required, 1..65535"
+func (ps *PropSchema) String() string {
+	items := []string{string(ps.propType)}
+	if ps.required {
+		items = append(items, "required")
+	}
+	if ps.min != nil || ps.max != nil {
+		r := ".."
+		if ps.min != nil {
+			r = ps.formatLimit(*ps.min) + r
+		}
+		if ps.max != nil {
+			r += ps.formatLimit(*ps.max)
+		}
+		items = append(items, r)
+	}
+	if len(ps.enum) > 0 {
+		items = append(items, "one of "+strings.Join(ps.enum, "|"))
+	}
+	if ps.pattern != nil {
+		items = append(items, "matching "+ps.pattern.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+func (ps *PropSchema) formatLimit(v float64) string {
+	if ps.propType == TypeDuration {
+		return fmt.Sprint(time.Duration(v))
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ValidationError describes one violation of a schema
+type ValidationError struct {
+	Sect     string
+	Prop     string
+	FileName string
+	Row      int
+	Msg      string
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	if e.FileName != "" {
+		sb.WriteString(fmt.Sprintf("%v:%v: ", e.FileName, e.Row))
+	}
+	sb.WriteString(fmt.Sprintf("[%v]", e.Sect))
+	if e.Prop != "" {
+		sb.WriteString(" ")
+		sb.WriteString(e.Prop)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Msg)
+	return sb.String()
+}
+
+// ValidationErrors holds all violations found by one call to Validate
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	var sb strings.Builder
+	for i, err := range errs {
+		if i > 0 {
+			sb.WriteRune('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Validate conf against schema. All violations are returned as ValidationErrors
+func (conf *Config) Validate(schema *Schema) error {
+	errs := ValidationErrors{}
+	addErr := func(sectName, propName, msg string) {
+		err := &ValidationError{Sect: sectName, Prop: propName, Msg: msg}
+		if origin := conf.getOrigin(sectName, propName); origin != nil {
+			err.FileName = origin.FileName
+			err.Row = origin.RowNumber
+		}
+		logEvent(conf.logger, slog.LevelWarn, "validation failed", originAttrs(conf.getOrigin(sectName, propName), slog.String("section", sectName), slog.String("key", propName), slog.String("error", msg))...)
+		errs = append(errs, err)
+	}
+
+	// sects and props in conf
+	for _, sectName := range conf.writeOrder() {
+		ss := schema.findSect(sectName)
+		if ss == nil {
+			if !schema.allowUnknownSects {
+				addErr(sectName, "", "unknown sect")
+			}
+			continue
+		}
+		props := conf.sects[sectName]
+		for _, propName := range conf.propOrder(sectName) {
+			ps := ss.findProp(propName)
+			if ps == nil {
+				if !ss.allowUnknown {
+					addErr(sectName, propName, "unknown property")
+				}
+				continue
+			}
+			if err := ps.check(props[propName], conf.lists[sectName][propName]); err != nil {
+				addErr(sectName, propName, err.Error())
+			}
+		}
+	}
+
+	// required sects and props missing in conf
+	for _, ss := range schema.sects {
+		props, exists := conf.sects[ss.name]
+		if !exists {
+			if ss.required {
+				addErr(ss.name, "", "required sect is missing")
+			}
+			continue
+		}
+		for _, ps := range ss.props {
+			if _, exists := props[ps.name]; !exists && ps.required {
+				addErr(ss.name, ps.name, "required property is missing")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check value against the description. items are the items of a list property, or nil
+func (ps *PropSchema) check(value string, items []string) error {
+	n, err := ps.measure(value, items)
+	if err != nil {
+		return err
+	}
+	if ps.min != nil && n < *ps.min {
+		return fmt.Errorf("%v is less than %v", value, ps.formatLimit(*ps.min))
+	}
+	if ps.max != nil && n > *ps.max {
+		return fmt.Errorf("%v is greater than %v", value, ps.formatLimit(*ps.max))
+	}
+	if len(ps.enum) > 0 {
+		found := false
+		for _, v := range ps.enum {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%v is not one of %v", value, strings.Join(ps.enum, ", "))
+		}
+	}
+	if ps.pattern != nil {
+		if loc := ps.pattern.FindStringIndex(value); loc == nil || loc[0] != 0 || loc[1] != len(value) {
+			return fmt.Errorf("%v does not match %v", value, ps.pattern)
+		}
+	}
+	return nil
+}
+
+// parse value according to the type, and return the number compared against min and max
+func (ps *PropSchema) measure(value string, items []string) (float64, error) {
+	switch ps.propType {
+	case TypeString:
+		return float64(utf8.RuneCountInString(value)), nil
+	case TypeList:
+		// lists written with list syntax already know their items
+		if items != nil {
+			return float64(len(items)), nil
+		}
+		return float64(len(splitList(value))), nil
+	default:
+		return parseNumber(ps.propType, value)
+	}
+}
+
+// parse s as t. Types without natural order returns 0
+func parseNumber(t PropType, s string) (float64, error) {
+	var err error
+	var n float64
+	switch t {
+	case TypeBool:
+		_, err = parseBool(s)
+	case TypeInt:
+		var v int64
+		v, err = parseInt(s, 64)
+		n = float64(v)
+	case TypeUint:
+		var v uint64
+		v, err = parseUint(s, 64)
+		n = float64(v)
+	case TypeFloat:
+		n, err = parseFloat(s, 64)
+	case TypeDuration:
+		var v time.Duration
+		v, err = parseDuration(s)
+		n = float64(v)
+	case TypeByteSize:
+		var v uint64
+		v, err = parseByteSize(s)
+		n = float64(v)
+	case TypeURL:
+		_, err = parseURL(s)
+	case TypeIP:
+		_, err = parseIP(s)
+	case TypeCIDR:
+		_, err = parseCIDR(s)
+	case TypeString, TypeList:
+	default:
+		return 0, fmt.Errorf("unknown type %v", t)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%v is not a valid %v", s, t)
+	}
+	return n, nil
+}
+
+// Read a schema file. It has the same format as a conf file, with one sect per described sect, and
+// one property per described property. Like this:
+//
+//	[@schema]
+//	allow_unknown_sects = false
+//
+//	[server]
+//	@required = true
+//	@allow_unknown = false
+//	port = int required min=1 max=65535
+//	mode = string enum=dev|prod
+//	host = string pattern=[a-z.]+
+//
+// pattern must be last, since the rest of the row is used as regular expression. The row is read like any
+// other conf row first, so only text that would be read as something else must be escaped with a backslash:
+// a # that would start a comment, brackets that would form a constant like [sect:prop], and ${. Other brackets,
+// like in [a-z.]+, are fine as they are. A backslash before #, [, ], $ or \ is always read as an escape, so a
+// regular expression escape of one of them needs one more: pattern=\\\[a\\\] matches the text [a]
+func LoadSchema(ctx *confContext.Context, fileName string, logger *Logger) (*Schema, error) {
+	conf, err := NewConfigFromFile(ctx, fileName, logger)
+	if err != nil {
+		return nil, err
+	}
+	return SchemaFromConfig(conf)
+}
+
+// Create a schema from a parsed schema file. See LoadSchema for the format
+func SchemaFromConfig(conf *Config) (*Schema, error) {
+	schema := NewSchema()
+	errs := ValidationErrors{}
+	addErr := func(sectName, propName string, err error) {
+		verr := &ValidationError{Sect: sectName, Prop: propName, Msg: err.Error()}
+		if origin := conf.getOrigin(sectName, propName); origin != nil {
+			verr.FileName = origin.FileName
+			verr.Row = origin.RowNumber
+		}
+		errs = append(errs, verr)
+	}
+	for _, sectName := range conf.writeOrder() {
+		props := conf.sects[sectName]
+		if sectName == schemaSectName {
+			for _, key := range conf.propOrder(sectName) {
+				if key != "allow_unknown_sects" {
+					addErr(sectName, key, fmt.Errorf("unknown schema setting"))
+				} else if allow, err := parseBool(props[key]); err != nil {
+					addErr(sectName, key, err)
+				} else {
+					schema.allowUnknownSects = allow
+				}
+			}
+			continue
+		}
+		ss := schema.Sect(sectName)
+		for _, key := range conf.propOrder(sectName) {
+			var err error
+			switch key {
+			case "@required":
+				ss.required, err = parseBool(props[key])
+			case "@allow_unknown":
+				ss.allowUnknown, err = parseBool(props[key])
+			default:
+				err = ss.Prop(key).parse(props[key])
+			}
+			if err != nil {
+				addErr(sectName, key, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return schema, errs
+	}
+	return schema, nil
+}
+
+// parse a description like "int required min=1 max=10"
+func (ps *PropSchema) parse(spec string) error {
+	spec = strings.TrimSpace(spec)
+	if i := strings.Index(spec, "pattern="); i != -1 {
+		re, err := regexp.Compile(spec[i+len("pattern="):])
+		if err != nil {
+			return err
+		}
+		ps.pattern = re
+		spec = spec[:i]
+	}
+	for i, item := range strings.Fields(spec) {
+		name, val, hasVal := strings.Cut(item, "=")
+		switch {
+		case i == 0 && !hasVal && name != "required":
+			if !PropType(name).known() {
+				return fmt.Errorf("unknown type %v", name)
+			}
+			ps.propType = PropType(name)
+		case name == "required" && !hasVal:
+			ps.required = true
+		case name == "min" || name == "max":
+			n, err := parseNumber(ps.limitType(), val)
+			if err != nil {
+				return err
+			}
+			if name == "min" {
+				ps.Min(n)
+			} else {
+				ps.Max(n)
+			}
+		case name == "enum":
+			ps.enum = strings.Split(val, "|")
+		default:
+			return fmt.Errorf("unknown setting %v", item)
+		}
+	}
+	return nil
+}
+
+// type of min and max values
+func (ps *PropSchema) limitType() PropType {
+	switch ps.propType {
+	case TypeString, TypeList:
+		return TypeUint
+	case TypeDuration, TypeByteSize, TypeInt, TypeUint:
+		return ps.propType
+	}
+	return TypeFloat
+}
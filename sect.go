@@ -4,6 +4,8 @@ type Sect struct {
 	name   string
 	Exists bool
 	conf   *Config
+	// set for sects from a snapshot
+	readOnly bool
 }
 
 func newSect(name string, exists bool, conf *Config) *Sect {
@@ -46,10 +48,33 @@ func (sect *Sect) PropVal(propName string) (string, bool) {
 	return propVal, exists
 }
 
+// Names of the props in the order they were set. Props from a macro are placed where the macro was used
+func (sect *Sect) PropNames() []string {
+	if !sect.Exists {
+		return []string{}
+	}
+	return sect.conf.propOrder(sect.name)
+}
+
+// Call fn for each prop, in PropNames order, until fn returns false
+func (sect *Sect) Range(fn func(prop *Prop) bool) {
+	for _, name := range sect.PropNames() {
+		if !fn(sect.Prop(name)) {
+			return
+		}
+	}
+}
+
 func (sect *Sect) Prop(name string) *Prop {
 	if !sect.Exists {
 		return newProp(name, "", false)
 	}
 	val, exists := sect.conf.sects[sect.name][name]
-	return newProp(name, val, exists)
+	prop := newProp(name, val, exists)
+	if exists {
+		prop.origin = sect.conf.getOrigin(sect.name, name)
+		prop.values = sect.conf.values[sect.name][name]
+		prop.items = sect.conf.lists[sect.name][name]
+	}
+	return prop
 }
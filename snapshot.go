@@ -0,0 +1,125 @@
+package config
+
+import "sync/atomic"
+
+// Snapshot is a read only copy of a config. It never changes, so it is safe for concurrent readers
+type Snapshot struct {
+	conf *Config
+}
+
+// Create a snapshot of conf. Later changes to conf don't show in the snapshot
+func (conf *Config) Snapshot() *Snapshot {
+	return &Snapshot{conf: conf.Clone()}
+}
+
+// Clone returns a deep copy of conf. Origins are shared, since they are never changed
+func (conf *Config) Clone() *Config {
+	clone := &Config{
+		sectNames:    append([]string(nil), conf.sectNames...),
+		sects:        conf.GetSects(),
+		propNames:    make(map[string][]string, len(conf.propNames)),
+		values:       make(map[string]map[string][]string, len(conf.values)),
+		lists:        make(map[string]map[string][]string, len(conf.lists)),
+		macros:       make(map[string]*macro, len(conf.macros)),
+		filesUsed:    append([]string(nil), conf.filesUsed...),
+		filesMissing: append([]string(nil), conf.filesMissing...),
+		origins:      make(map[string]map[string]*Origin, len(conf.origins)),
+		parseErrors:  append(ParseErrors(nil), conf.parseErrors...),
+		report: parseReport{
+			skippedSects:    append([]SkippedSect(nil), conf.report.skippedSects...),
+			duplicateProps:  append([]DuplicateProp(nil), conf.report.duplicateProps...),
+			missingIncludes: append([]MissingInclude(nil), conf.report.missingIncludes...),
+		},
+		logger: conf.logger,
+		schema: conf.schema,
+	}
+	for sectName, names := range conf.propNames {
+		clone.propNames[sectName] = append([]string(nil), names...)
+	}
+	for sectName, values := range conf.values {
+		clone.values[sectName] = make(map[string][]string, len(values))
+		for key, v := range values {
+			clone.values[sectName][key] = append([]string(nil), v...)
+		}
+	}
+	for sectName, lists := range conf.lists {
+		for key, items := range lists {
+			clone.setListItems(sectName, key, append([]string{}, items...))
+		}
+	}
+	for name, m := range conf.macros {
+		clone.macros[name] = m
+	}
+	for sectName, origins := range conf.origins {
+		clone.origins[sectName] = make(map[string]*Origin, len(origins))
+		for key, origin := range origins {
+			clone.origins[sectName][key] = origin
+		}
+	}
+	return clone
+}
+
+// Sect names in the order they were found
+func (s *Snapshot) SectNames() []string {
+	return s.conf.SectNames()
+}
+
+// Sect from the snapshot. It can not be changed
+func (s *Snapshot) Sect(name string) *Sect {
+	sect := s.conf.Sect(name)
+	sect.readOnly = true
+	return sect
+}
+
+func (s *Snapshot) PropVal(sectName string, propName string) (string, bool) {
+	return s.conf.PropVal(sectName, propName)
+}
+
+func (s *Snapshot) PropOrDefault(sectName string, propName string, defVal string) string {
+	return s.conf.PropOrDefault(sectName, propName, defVal)
+}
+
+// Return a copy of all sects and their props
+func (s *Snapshot) GetSects() map[string]map[string]string {
+	return s.conf.GetSects()
+}
+
+// Return a copy of the props in sect
+func (s *Snapshot) GetProperties(sect string) (map[string]string, error) {
+	return s.conf.GetProperties(sect)
+}
+
+// Config returns a copy of the snapshot, that may be changed
+func (s *Snapshot) Config() *Config {
+	return s.conf.Clone()
+}
+
+// Holder holds the current snapshot of a config. Readers may load it from any goroutine, while
+// a new snapshot is stored, like after a reload
+type Holder struct {
+	snap atomic.Pointer[Snapshot]
+}
+
+// Create a holder with a snapshot of conf
+func NewHolder(conf *Config) *Holder {
+	h := &Holder{}
+	h.Store(conf)
+	return h
+}
+
+// Current snapshot
+func (h *Holder) Load() *Snapshot {
+	return h.snap.Load()
+}
+
+// Store a snapshot of conf, and return it
+func (h *Holder) Store(conf *Config) *Snapshot {
+	snap := conf.Snapshot()
+	h.snap.Store(snap)
+	return snap
+}
+
+// Store a snapshot of conf, and return the previous one
+func (h *Holder) Swap(conf *Config) *Snapshot {
+	return h.snap.Swap(conf.Snapshot())
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAccessorsReturnCopies(t *testing.T) {
+	conf, err := NewConfigFromString(nil, "[server]\nhost = localhost\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.GetSects()["server"]["host"] = "changed"
+	props, _ := conf.GetProperties("server")
+	props["port"] = "80"
+	conf.SectNames()[0] = "changed"
+	if host, _ := conf.PropVal("server", "host"); host != "localhost" {
+		t.Errorf("GetSects changed conf: %v", host)
+	}
+	if _, exists := conf.PropVal("server", "port"); exists {
+		t.Error("GetProperties changed conf")
+	}
+	if conf.SectNames()[0] != "server" {
+		t.Errorf("SectNames changed conf: %v", conf.SectNames())
+	}
+
+	snap := conf.Snapshot()
+	conf.setProp("server", "host", "example.com", &Origin{})
+	if host, _ := snap.PropVal("server", "host"); host != "localhost" {
+		t.Errorf("snapshot changed with conf: %v", host)
+	}
+}
+
+// run with -race
+func TestHolderConcurrency(t *testing.T) {
+	conf, err := NewConfigFromString(nil, "[server]\nhost = localhost\nport = 0\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHolder(conf)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				snap := h.Load()
+				// a snapshot never changes, so port and gen always agree
+				port := snap.Sect("server").Prop("port").IntOrDefault(-1)
+				if gen := snap.PropOrDefault("server", "gen", "0"); gen != strconv.Itoa(port) {
+					t.Errorf("inconsistent snapshot: port %v, gen %v", port, gen)
+					return
+				}
+				for _, props := range snap.GetSects() {
+					_ = len(props)
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 200; i++ {
+		conf.setProp("server", "port", strconv.Itoa(i), &Origin{})
+		conf.setProp("server", "gen", strconv.Itoa(i), &Origin{})
+		if i%2 == 0 {
+			h.Store(conf)
+		} else {
+			h.Swap(conf)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if port, _ := h.Load().PropVal("server", "port"); port != "200" {
+		t.Errorf("expected last snapshot, got port %v", port)
+	}
+}
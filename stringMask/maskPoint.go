@@ -14,7 +14,7 @@ type MaskPoint struct {
 
 // init MaskPoint
 func (cm *StringMask) NewMaskPoint(pos int) MaskPoint {
-	if pos < 0 {
+	if pos < 0 || pos >= len(cm.String) {
 		return MaskPoint{
 			Rune: rune(0),
 			Mask: rune(0),
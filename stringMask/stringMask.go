@@ -7,11 +7,12 @@ import (
 )
 
 type StringMask struct {
-	String     []rune
-	mask       []rune
-	tags       map[int]string
-	Comment    MaskPoint
-	InitMarker rune
+	String        []rune
+	mask          []rune
+	tags          map[int]string
+	Comment       MaskPoint
+	InitMarker    rune
+	commentMarker rune
 }
 
 // init cmask. Default CommentMask is ⛔
@@ -41,8 +42,10 @@ func NewStringMask(indataString string, initMarker rune, commentOptions ...rune)
 		sm.Comment.Mask = commentOptions[1]
 	}
 
+	sm.commentMarker = commentMarker
+
 	// Loop thru data string and set initMarkers and endMarker
-	for i, c := range indataString {
+	for i, c := range sm.String {
 		if commentMarkerDefined && c == commentMarker && sm.Comment.Pos == -1 {
 			sm.mask[i] = sm.Comment.Mask
 			sm.Comment.Pos = i
@@ -93,12 +96,44 @@ func isSpaces(runesToMatch []rune) bool {
 	return false
 }
 
+// Mask escaped runes, like \#, with setMaskTo. The escaped rune is stored as a tag at the position of the escapeRune.
+// If keepEscapeRune is true, the tag will hold both runes. An escaped commentMarker is not a comment, so the comment is searched for again
+func (sm *StringMask) MaskEscapes(escapeRune rune, escapable string, setMaskTo rune, keepEscapeRune bool) *[]MaskPoint {
+	points := make([]MaskPoint, 0)
+
+	// forget the comment, it will be found again below
+	if sm.Comment.Pos != -1 {
+		sm.mask[sm.Comment.Pos] = sm.InitMarker
+		sm.Comment.Pos = -1
+	}
+	for i := 0; i < len(sm.String); i++ {
+		c := sm.String[i]
+		if c == escapeRune && i+1 < len(sm.String) && strings.ContainsRune(escapable, sm.String[i+1]) {
+			points = append(points, sm.NewMaskPoint(i))
+			if keepEscapeRune {
+				sm.tags[i] = string(sm.String[i : i+2])
+			} else {
+				sm.tags[i] = string(sm.String[i+1])
+			}
+			sm.mask[i] = setMaskTo
+			sm.mask[i+1] = setMaskTo
+			i++
+		} else if sm.commentMarker != rune(0) && c == sm.commentMarker {
+			// everything after the comment marker is comment
+			sm.mask[i] = sm.Comment.Mask
+			sm.Comment.Pos = i
+			break
+		}
+	}
+	return &points
+}
+
 // string!
 func (sm *StringMask) GetString(whereMaskIs rune, useTagsWhereMaskIs ...rune) string {
 	endPoint := sm.GetEndPoint()
 	sb := strings.Builder{}
 	for i, c := range sm.String {
-		if len(useTagsWhereMaskIs) > 0 && sm.mask[i] == useTagsWhereMaskIs[0] {
+		if len(useTagsWhereMaskIs) > 0 && runeMatches(sm.mask[i], &useTagsWhereMaskIs) {
 			if val, exists := sm.tags[i]; exists {
 				sb.WriteString(val)
 			}
@@ -119,12 +154,13 @@ func (sm *StringMask) GetStrings(whereMaskIs rune, useTagsWhereMaskIs ...rune) [
 	sb := strings.Builder{}
 	delimiterWritten := true
 	for i, c := range sm.String {
-		if len(useTagsWhereMaskIs) > 0 && sm.mask[i] == useTagsWhereMaskIs[0] {
+		// tags are part of the string, not delimiters
+		if len(useTagsWhereMaskIs) > 0 && runeMatches(sm.mask[i], &useTagsWhereMaskIs) {
 			if val, exists := sm.tags[i]; exists {
 				sb.WriteString(val)
+				delimiterWritten = false
 			}
-		}
-		if sm.mask[i] == whereMaskIs {
+		} else if sm.mask[i] == whereMaskIs {
 			sb.WriteRune(c)
 			delimiterWritten = false
 		} else if !delimiterWritten {
@@ -0,0 +1,458 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reads toml into sects. Tables and dotted keys become sects named like parent.child. Arrays of scalars
+// become list props, numbers are written in decimal and booleans and dates are stored as written. Arrays of
+// tables are not supported
+type tomlParser struct {
+	fr    *formatReader
+	text  []rune
+	pos   int
+	row   int
+	table string
+}
+
+func readTOML(fr *formatReader, data []byte) error {
+	tp := &tomlParser{fr: fr, text: []rune(strings.ReplaceAll(string(data), "\r\n", "\n")), row: 1}
+	for {
+		tp.skipSpace(true)
+		if tp.pos >= len(tp.text) {
+			return nil
+		}
+		var err error
+		if tp.peek() == '[' {
+			err = tp.parseTable()
+		} else {
+			err = tp.parseKeyValue()
+		}
+		if err != nil {
+			return err
+		}
+		if err := tp.endOfRow(); err != nil {
+			return err
+		}
+	}
+}
+
+func (tp *tomlParser) peek() rune {
+	if tp.pos >= len(tp.text) {
+		return 0
+	}
+	return tp.text[tp.pos]
+}
+
+func (tp *tomlParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(tp.text[tp.pos:min(tp.pos+len(s), len(tp.text))]), s)
+}
+
+func (tp *tomlParser) next() rune {
+	r := tp.peek()
+	tp.pos++
+	if r == '\n' {
+		tp.row++
+	}
+	return r
+}
+
+func (tp *tomlParser) errorf(format string, args ...any) error {
+	return tp.fr.errorAt(tp.row, format, args...)
+}
+
+// skip spaces and comments, and newlines if multiline is set
+func (tp *tomlParser) skipSpace(multiline bool) {
+	for tp.pos < len(tp.text) {
+		switch r := tp.peek(); {
+		case r == ' ' || r == '\t':
+			tp.next()
+		case r == '\n' && multiline:
+			tp.next()
+		case r == '#':
+			for tp.pos < len(tp.text) && tp.peek() != '\n' {
+				tp.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// only space and a comment may follow on the row
+func (tp *tomlParser) endOfRow() error {
+	tp.skipSpace(false)
+	if tp.pos < len(tp.text) && tp.next() != '\n' {
+		return tp.errorf("expected end of row")
+	}
+	return nil
+}
+
+// [table] or [parent.child]
+func (tp *tomlParser) parseTable() error {
+	tp.next()
+	if tp.peek() == '[' {
+		return tp.errorf("arrays of tables are not supported")
+	}
+	keys, err := tp.parseKey()
+	if err != nil {
+		return err
+	}
+	if tp.next() != ']' {
+		return tp.errorf("expected ] after table name")
+	}
+	tp.table = strings.Join(keys, ".")
+	tp.fr.sect(tp.table)
+	return nil
+}
+
+// key = value, where key may be dotted
+func (tp *tomlParser) parseKeyValue() error {
+	keys, err := tp.parseKey()
+	if err != nil {
+		return err
+	}
+	if tp.next() != '=' {
+		return tp.errorf("expected = after key %v", strings.Join(keys, "."))
+	}
+	tp.skipSpace(false)
+	return tp.parseValue(tp.table, keys)
+}
+
+// dotted keys of bare and quoted parts, like a."b c".d
+func (tp *tomlParser) parseKey() ([]string, error) {
+	keys := make([]string, 0, 1)
+	for {
+		tp.skipSpace(false)
+		var key string
+		switch r := tp.peek(); {
+		case r == '"':
+			s, err := tp.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case r == '\'':
+			s, err := tp.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := tp.pos
+			for isBareKeyRune(tp.peek()) {
+				tp.next()
+			}
+			if start == tp.pos {
+				return nil, tp.errorf("expected key")
+			}
+			key = string(tp.text[start:tp.pos])
+		}
+		keys = append(keys, key)
+		tp.skipSpace(false)
+		if tp.peek() != '.' {
+			return keys, nil
+		}
+		tp.next()
+	}
+}
+
+func isBareKeyRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
+
+// parse the value of keys in table. Inline tables add their keys to a sect of their own
+func (tp *tomlParser) parseValue(table string, keys []string) error {
+	sectName := strings.Join(append(sectPath(table), keys[:len(keys)-1]...), ".")
+	row := tp.row
+	if tp.peek() == '{' {
+		return tp.parseInlineTable(strings.Join(append(sectPath(sectName), keys[len(keys)-1]), "."))
+	}
+	if tp.peek() == '[' {
+		items, err := tp.parseArray()
+		if err != nil {
+			return err
+		}
+		if sectName != table {
+			tp.fr.sect(sectName)
+		}
+		return tp.fr.list(sectName, keys[len(keys)-1], items, row)
+	}
+	value, err := tp.parseScalar()
+	if err != nil {
+		return err
+	}
+	if sectName != table {
+		tp.fr.sect(sectName)
+	}
+	return tp.fr.prop(sectName, keys[len(keys)-1], value, row)
+}
+
+// sectName as the start of a path, where the root sect is empty
+func sectPath(sectName string) []string {
+	if sectName == "" {
+		return nil
+	}
+	return []string{sectName}
+}
+
+// { key = value, ... }
+func (tp *tomlParser) parseInlineTable(sectName string) error {
+	tp.next()
+	tp.fr.sect(sectName)
+	tp.skipSpace(false)
+	if tp.peek() == '}' {
+		tp.next()
+		return nil
+	}
+	for {
+		keys, err := tp.parseKey()
+		if err != nil {
+			return err
+		}
+		if tp.next() != '=' {
+			return tp.errorf("expected = after key %v", strings.Join(keys, "."))
+		}
+		tp.skipSpace(false)
+		if err := tp.parseValue(sectName, keys); err != nil {
+			return err
+		}
+		tp.skipSpace(false)
+		switch tp.next() {
+		case ',':
+			tp.skipSpace(false)
+		case '}':
+			return nil
+		default:
+			return tp.errorf("expected , or } in inline table")
+		}
+	}
+}
+
+// strings, numbers, booleans and dates. Other bare values are not allowed, since strings must be quoted
+func (tp *tomlParser) parseScalar() (string, error) {
+	switch {
+	case tp.hasPrefix(`"""`):
+		return tp.parseMultilineString(`"""`)
+	case tp.hasPrefix("'''"):
+		return tp.parseMultilineString("'''")
+	case tp.peek() == '"':
+		return tp.parseBasicString()
+	case tp.peek() == '\'':
+		return tp.parseLiteralString()
+	case tp.peek() == '[':
+		return "", tp.errorf("arrays are only supported as values of keys")
+	case tp.peek() == '{':
+		return "", tp.errorf("inline tables are only supported as values of keys")
+	}
+	start := tp.pos
+	for tp.pos < len(tp.text) && !strings.ContainsRune(" \t\n#,]}", tp.peek()) {
+		tp.next()
+	}
+	// dates may have a space between date and time
+	if tp.pos-start == 10 && tp.peek() == ' ' && tp.pos+1 < len(tp.text) && tp.text[tp.pos+1] >= '0' && tp.text[tp.pos+1] <= '9' {
+		tp.next()
+		for tp.pos < len(tp.text) && !strings.ContainsRune(" \t\n#,]}", tp.peek()) {
+			tp.next()
+		}
+	}
+	if start == tp.pos {
+		return "", tp.errorf("expected value")
+	}
+	value := string(tp.text[start:tp.pos])
+	if !isTOMLBareValue(value) {
+		return "", tp.errorf("invalid value %v, strings must be quoted", value)
+	}
+	return normalizeTOMLNumber(value), nil
+}
+
+// layouts of toml dates and times, after the T separator and Z are upper cased
+var tomlTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "15:04:05"}
+
+// check if s is a boolean, a number or a date
+func isTOMLBareValue(s string) bool {
+	switch s {
+	case "true", "false", "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return true
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return true
+	}
+	// go also reads hex floats, and inf and nan in any case
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err == nil && strings.ContainsAny(s, "0123456789") && !strings.ContainsAny(s, "xXpP") {
+		return true
+	}
+	t := strings.ToUpper(strings.Replace(s, " ", "T", 1))
+	for _, layout := range tomlTimeLayouts {
+		if _, err := time.Parse(layout, t); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// write numbers so the typed accessors can read them. Integers like 8_080, 0x1f, 0o17 and 0b11 become
+// decimal, and underscores are removed from floats. Anything else is kept as written
+func normalizeTOMLNumber(s string) string {
+	if !strings.ContainsAny(s, "0123456789") {
+		return s
+	}
+	// leading zeros are not allowed in toml, and would be read as octal here
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != 'x' && digits[1] != 'o' && digits[1] != 'b' && digits[1] != '.' && digits[1] != 'e' && digits[1] != 'E' {
+		return s
+	}
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return strconv.FormatInt(i, 10)
+	}
+	if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return strconv.FormatUint(u, 10)
+	}
+	if f := strings.ReplaceAll(s, "_", ""); f != s {
+		if _, err := strconv.ParseFloat(f, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// [a, b, c], possibly on several rows. Nested arrays are not supported
+func (tp *tomlParser) parseArray() ([]string, error) {
+	tp.next()
+	items := make([]string, 0)
+	for {
+		tp.skipSpace(true)
+		if tp.peek() == ']' {
+			tp.next()
+			return items, nil
+		}
+		if tp.peek() == '[' || tp.peek() == '{' {
+			return nil, tp.errorf("only arrays of scalars are supported")
+		}
+		item, err := tp.parseScalar()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		tp.skipSpace(true)
+		switch tp.next() {
+		case ',':
+		case ']':
+			return items, nil
+		default:
+			return nil, tp.errorf("expected , or ] in array")
+		}
+	}
+}
+
+// "..." with escapes
+func (tp *tomlParser) parseBasicString() (string, error) {
+	tp.next()
+	var sb strings.Builder
+	for {
+		if tp.pos >= len(tp.text) || tp.peek() == '\n' {
+			return "", tp.errorf("unterminated string")
+		}
+		switch r := tp.next(); r {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if err := tp.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+}
+
+// '...' without escapes
+func (tp *tomlParser) parseLiteralString() (string, error) {
+	tp.next()
+	start := tp.pos
+	for {
+		if tp.pos >= len(tp.text) || tp.peek() == '\n' {
+			return "", tp.errorf("unterminated string")
+		}
+		if tp.next() == '\'' {
+			return string(tp.text[start : tp.pos-1]), nil
+		}
+	}
+}
+
+// multiline strings, with three quotes or three apostrophes. A newline right after the opening quotes is not part of the string
+func (tp *tomlParser) parseMultilineString(quotes string) (string, error) {
+	tp.pos += 3
+	if tp.peek() == '\n' {
+		tp.next()
+	}
+	var sb strings.Builder
+	for {
+		if tp.pos >= len(tp.text) {
+			return "", tp.errorf("unterminated string")
+		}
+		// up to two quotes may be part of the string, just before the closing quotes
+		if tp.hasPrefix(quotes) && !tp.hasPrefix(quotes+quotes[:1]) {
+			tp.pos += 3
+			return sb.String(), nil
+		}
+		r := tp.next()
+		if r == '\\' && quotes == `"""` {
+			// a backslash last on a row trims the newline and leading white space on the next rows
+			tp.skipSpace(false)
+			if tp.peek() == '\n' {
+				for tp.peek() == '\n' || tp.peek() == ' ' || tp.peek() == '\t' {
+					tp.next()
+				}
+				continue
+			}
+			if err := tp.parseEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+}
+
+// the rune after a backslash
+func (tp *tomlParser) parseEscape(sb *strings.Builder) error {
+	switch r := tp.next(); r {
+	case 'b':
+		sb.WriteRune('\b')
+	case 't':
+		sb.WriteRune('\t')
+	case 'n':
+		sb.WriteRune('\n')
+	case 'f':
+		sb.WriteRune('\f')
+	case 'r':
+		sb.WriteRune('\r')
+	case 'e':
+		sb.WriteRune('\x1b')
+	case '"', '\\':
+		sb.WriteRune(r)
+	case 'u', 'U':
+		n := 4
+		if r == 'U' {
+			n = 8
+		}
+		if tp.pos+n > len(tp.text) {
+			return tp.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(string(tp.text[tp.pos:tp.pos+n]), 16, 32)
+		if err != nil {
+			return tp.errorf("invalid unicode escape")
+		}
+		tp.pos += n
+		sb.WriteRune(rune(code))
+	default:
+		return tp.errorf("invalid escape \\%c", r)
+	}
+	return nil
+}
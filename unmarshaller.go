@@ -22,9 +22,12 @@ func unMarshall(ctx *conf.Context, um unMarshaller, conf *Config, logger *Logger
 	// Get next data
 	for um.scan() {
 
-		// Do some preprocessing if needed
+		// Do some preprocessing if needed. In lenient mode, rows with errors are skipped
 		if err := um.prepareData(ctx, conf); err != nil {
-			return err
+			if err := conf.handleRowError(ctx, um.getFileRowData(), err); err != nil {
+				return err
+			}
+			continue
 		}
 
 		// get appropriate dataStrategy
@@ -32,7 +35,9 @@ func unMarshall(ctx *conf.Context, um unMarshaller, conf *Config, logger *Logger
 
 		// execute strategy
 		if err := ds.execute(ctx, conf, um, logger); err != nil {
-			return err
+			if err := conf.handleRowError(ctx, um.getFileRowData(), err); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
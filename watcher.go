@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	confContext "github.com/grufgran/config/context"
+)
+
+// ChangeFunc is called by the Watcher after each reload. conf is a copy of the new config, that the
+// subscriber may change. If the reload failed, err is set, conf is a copy of the config still in use and diff is nil
+type ChangeFunc func(conf *Config, diff *ConfigDiff, err error)
+
+// Watcher keeps a config up to date, by polling all files used to create it
+type Watcher struct {
+	ctx      *confContext.Context
+	fileName string
+	logger   *Logger
+	interval time.Duration
+	holder   Holder
+	// held during a whole reload, so reloads finish in the order they started
+	reloadMu    sync.Mutex
+	mu          sync.Mutex
+	stamps      map[string]fileStamp
+	subscribers []ChangeFunc
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// what we know about a file, the last time it was read
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// Create a watcher and load the config. ctx is copied before each parse, so it can be reused between reloads.
+// interval is the time between polls, and must be positive
+func NewWatcher(ctx *confContext.Context, fileName string, logger *Logger, interval time.Duration) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("watcher interval must be positive, got %v", interval)
+	}
+	if ctx == nil {
+		ctx = confContext.NewContext(nil)
+	}
+	w := &Watcher{
+		ctx:      ctx,
+		fileName: fileName,
+		logger:   logger,
+		interval: interval,
+	}
+	conf, stamps, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.holder.Store(conf)
+	w.stamps = stamps
+	return w, nil
+}
+
+// Current config, as a copy that may be changed. Safe to call from any goroutine. Use Snapshot to read
+// the config without copying it
+func (w *Watcher) Config() *Config {
+	return w.holder.Load().Config()
+}
+
+// Current snapshot. Safe to call from any goroutine
+func (w *Watcher) Snapshot() *Snapshot {
+	return w.holder.Load()
+}
+
+// Subscribe to reloads
+func (w *Watcher) Subscribe(fn ChangeFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Start polling in a new goroutine
+func (w *Watcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.poll(w.stop, w.done)
+}
+
+// Stop polling, and wait for the polling goroutine to finish
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (w *Watcher) poll(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload the config now, whether files has changed or not. Subscribers are notified if the config differs.
+// Reloads run one at a time, so subscribers must not call Reload
+func (w *Watcher) Reload() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	conf, stamps, err := w.load()
+
+	w.mu.Lock()
+	subscribers := append([]ChangeFunc(nil), w.subscribers...)
+	old := w.holder.Load()
+	var diff *ConfigDiff
+	if err == nil {
+		diff = Diff(old.conf, conf)
+		// stored even without differences, since origins may have moved
+		w.holder.Store(conf)
+	} else {
+		// the files of the last good config are still watched, but the failed parse is not
+		// tried again until some file changes
+		for fileName := range w.stamps {
+			if _, exists := stamps[fileName]; !exists {
+				stamps[fileName] = stampFile(w.ctx, fileName)
+			}
+		}
+	}
+	w.stamps = stamps
+	w.mu.Unlock()
+
+	// notify subscribers. The config in the held snapshot is shared, so each of them gets a copy of it
+	if err != nil {
+		for _, fn := range subscribers {
+			fn(old.Config(), nil, err)
+		}
+		return err
+	}
+	if !diff.IsEmpty() {
+		for _, fn := range subscribers {
+			fn(conf.Clone(), diff, nil)
+		}
+	}
+	return nil
+}
+
+// parse the config and stamp all files used. The stamps are returned even if the parse failed
+func (w *Watcher) load() (*Config, map[string]fileStamp, error) {
+	conf, err := NewConfigFromFile(w.ctx.Copy(), w.fileName, w.logger)
+	stamps := make(map[string]fileStamp)
+	if conf != nil {
+		for _, fileName := range conf.filesUsed {
+			stamps[fileName] = stampFile(w.ctx, fileName)
+		}
+		for _, fileName := range conf.filesMissing {
+			stamps[fileName] = stampFile(w.ctx, fileName)
+		}
+	}
+	if err != nil {
+		return nil, stamps, err
+	}
+	return conf, stamps, nil
+}
+
+// check if any of the files has changed, or appeared, since last load
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for fileName, stamp := range w.stamps {
+		if stampFile(w.ctx, fileName) != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+func stampFile(ctx *confContext.Context, fileName string) fileStamp {
+	info, err := ctx.Stat(fileName)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.conf")
+	extra := filepath.Join(dir, "extra.conf")
+	writeFile(t, root, "[server]\nhost = localhost\nport = 80\n[include_if_exists = extra.conf]\n")
+
+	w, err := NewWatcher(nil, root, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diffs []*ConfigDiff
+	w.Subscribe(func(conf *Config, diff *ConfigDiff, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		diffs = append(diffs, diff)
+	})
+	if w.changed() {
+		t.Fatal("nothing has changed yet")
+	}
+
+	// the optional include shows up
+	writeFile(t, extra, "[extra]\nkey = value\n")
+	if !w.changed() {
+		t.Fatal("new include file not detected")
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// change a property in the root file
+	writeFile(t, root, "[server]\nhost = example.com\n[include_if_exists = extra.conf]\n")
+	if !w.changed() {
+		t.Fatal("changed root file not detected")
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 diffs, got %v", len(diffs))
+	}
+	if len(diffs[0].AddedSects) != 1 || diffs[0].AddedSects[0] != "extra" {
+		t.Errorf("unexpected first diff: %+v", diffs[0])
+	}
+	if len(diffs[1].Props) != 2 || diffs[1].Props[0].Kind != PropChanged || diffs[1].Props[1].Kind != PropRemoved {
+		t.Errorf("unexpected second diff: %+v", diffs[1])
+	}
+	if host, _ := w.Config().PropVal("server", "host"); host != "example.com" {
+		t.Errorf("config not swapped, host = %v", host)
+	}
+
+	// rows that only moved give no diff, but the origins are updated
+	writeFile(t, root, "\n\n[server]\nhost = example.com\n[include_if_exists = extra.conf]\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Errorf("moved rows should not notify, got %v diffs", len(diffs))
+	}
+	if origin := w.Snapshot().Sect("server").Prop("host").Origin(); origin == nil || origin.RowNumber != 4 {
+		t.Errorf("origin not updated: %v", origin)
+	}
+
+	// the config is a copy, so changing it doesn't change the snapshot
+	if err := w.Config().SetProp("server", "host", "changed"); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := w.Snapshot().PropVal("server", "host"); host != "example.com" {
+		t.Errorf("snapshot changed through Config, host = %v", host)
+	}
+}
+
+func TestWatcherSubscribers(t *testing.T) {
+	if _, err := NewWatcher(nil, "testdata/test1.conf", nil, 0); err == nil {
+		t.Error("interval 0 should not be allowed")
+	}
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root.conf")
+	writeFile(t, root, "[server]\nhost = localhost\n")
+	w, err := NewWatcher(nil, root, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs int
+	w.Subscribe(func(conf *Config, diff *ConfigDiff, err error) {
+		if err != nil {
+			errs++
+			return
+		}
+		// each subscriber gets its own copy
+		conf.SetProp("server", "host", "changed")
+	})
+
+	writeFile(t, root, "[server]\nhost = example.com\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := w.Snapshot().PropVal("server", "host"); host != "example.com" {
+		t.Errorf("snapshot changed by a subscriber, host = %v", host)
+	}
+
+	// a broken file is reported once, not at every poll
+	writeFile(t, root, "[server]\nhost = [missing:constant]\n")
+	if !w.changed() {
+		t.Fatal("broken file not detected")
+	}
+	if err := w.Reload(); err == nil {
+		t.Fatal("expected an error from the broken file")
+	}
+	if w.changed() || errs != 1 {
+		t.Errorf("broken file should be reloaded once, changed %v, errors %v", w.changed(), errs)
+	}
+	writeFile(t, root, "[server]\nhost = fixed.com\n")
+	if !w.changed() {
+		t.Error("fixed file not detected")
+	}
+}
+
+func writeFile(t *testing.T, fileName, content string) {
+	t.Helper()
+	if err := os.WriteFile(fileName, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
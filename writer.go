@@ -0,0 +1,291 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// values longer than this are split on several rows with ending backslashes
+const maxRowLen = 100
+
+// WriteTo writes conf in the same format as NewConfigFromFile reads. Sects are written in sectNames order and
+// props in the order they were set. Values with newlines, or leading and trailing white space, are written as hereDocs.
+// #, [, ], $ and \ in values are escaped with a backslash, so a value with one backslash is written with two.
+// Reading the result gives a config with the same sects and props
+func (conf *Config) WriteTo(w io.Writer) (int64, error) {
+	return conf.write(w, false)
+}
+
+// WriteAnnotatedTo writes conf like WriteTo, with a comment before every property telling where it got its
+// value, and comments last about sects skipped because of claims
+func (conf *Config) WriteAnnotatedTo(w io.Writer) (int64, error) {
+	return conf.write(w, true)
+}
+
+func (conf *Config) write(w io.Writer, annotate bool) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for i, sectName := range conf.writeOrder() {
+		if err := checkSectName(sectName); err != nil {
+			return cw.n, err
+		}
+		if i > 0 {
+			cw.writeString("\n")
+		}
+		// the other escapable runes are not allowed in sect names
+		cw.writeString("[")
+		cw.writeString(strings.ReplaceAll(sectName, `\`, `\\`))
+		cw.writeString("]\n")
+		props := conf.sects[sectName]
+		for _, key := range conf.propOrder(sectName) {
+			if origin := conf.getOrigin(sectName, key); annotate && origin != nil {
+				cw.writeString("# ")
+				cw.writeString(origin.String())
+				cw.writeString("\n")
+			}
+			if items, isList := conf.lists[sectName][key]; isList {
+				prop, err := formatList(key, items)
+				if err != nil {
+					return cw.n, fmt.Errorf("[%v] %v: %w", sectName, key, err)
+				}
+				cw.writeString(prop)
+				continue
+			}
+			// accumulated values are written as repeated keys
+			values, exists := conf.values[sectName][key]
+			if !exists {
+				values = []string{props[key]}
+			}
+			for _, value := range values {
+				prop, err := formatProp(key, value)
+				if err != nil {
+					return cw.n, fmt.Errorf("[%v] %v: %w", sectName, key, err)
+				}
+				cw.writeString(prop)
+			}
+		}
+	}
+	if annotate && len(conf.report.skippedSects) > 0 {
+		cw.writeString("\n")
+		for _, skipped := range conf.report.skippedSects {
+			cw.writeString(fmt.Sprintf("# skipped [%v] at %v, claims %v not fulfilled\n", skipped.Name, skipped.Origin, skipped.Claims))
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// sectNames in order, without duplicates, followed by sects not found in sectNames
+func (conf *Config) writeOrder() []string {
+	names := make([]string, 0, len(conf.sectNames))
+	seen := make(map[string]struct{}, len(conf.sectNames))
+	for _, name := range conf.sectNames {
+		if _, done := seen[name]; !done {
+			names = append(names, name)
+			seen[name] = struct{}{}
+		}
+	}
+	for _, name := range sortedKeys(conf.sects) {
+		if _, done := seen[name]; !done {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// format one property. Choose between key = value, hereDoc or multiline with ending backslashes
+func formatProp(key, value string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(escape(key))
+	sb.WriteString(" =")
+	if err := formatValue(&sb, value); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// format a list property as key = [a, "b,c"]. Single items, that would not be read as a list, and items
+// that can not be quoted are written as repeated key[] = item rows
+func formatList(key string, items []string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, item := range items {
+		if len(items) == 1 || strings.ContainsAny(item, "\"\n\r") {
+			for _, item := range items {
+				sb.WriteString(escape(key))
+				sb.WriteString("[] =")
+				if err := formatValue(&sb, item); err != nil {
+					return "", err
+				}
+			}
+			return sb.String(), nil
+		}
+	}
+	sb.WriteString(escape(key))
+	sb.WriteString(" = [")
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if item == "" || strings.ContainsAny(item, ",:[]") || strings.TrimSpace(item) != item {
+			sb.WriteString("\"")
+			sb.WriteString(escape(item))
+			sb.WriteString("\"")
+			continue
+		}
+		sb.WriteString(escape(item))
+	}
+	sb.WriteString("]\n")
+	return sb.String(), nil
+}
+
+// format the part after the =-sign
+func formatValue(sb *strings.Builder, value string) error {
+	if strings.ContainsRune(value, '\r') {
+		return fmt.Errorf("carriage return can not be written")
+	}
+	switch {
+	case needsHereDoc(value):
+		rows := strings.Split(value, "\n")
+		for i := range rows {
+			rows[i] = escape(rows[i])
+		}
+		marker := hereDocMarker(rows)
+		sb.WriteString(" <<")
+		sb.WriteString(marker)
+		sb.WriteString("\n")
+		for _, row := range rows {
+			sb.WriteString(row)
+			sb.WriteString("\n")
+		}
+		sb.WriteString(marker)
+		sb.WriteString("\n")
+	case len([]rune(value)) > maxRowLen:
+		rows := splitRows(value)
+		sb.WriteString(" ")
+		for i, row := range rows {
+			sb.WriteString(row)
+			if i < len(rows)-1 {
+				sb.WriteString("\\")
+			}
+			sb.WriteString("\n")
+		}
+	case value == "":
+		sb.WriteString("\n")
+	default:
+		sb.WriteString(" ")
+		sb.WriteString(escape(value))
+		sb.WriteString("\n")
+	}
+	return nil
+}
+
+// values that would be changed by the parser, if written on a single row
+func needsHereDoc(value string) bool {
+	if value == "" {
+		return false
+	}
+	runes := []rune(value)
+	return strings.ContainsRune(value, '\n') || unicode.IsSpace(runes[0]) || unicode.IsSpace(runes[len(runes)-1]) || strings.HasPrefix(value, "<<")
+}
+
+// find a hereDoc marker not present among rows
+func hereDocMarker(rows []string) string {
+	marker := "EOF"
+	for i := 1; ; i++ {
+		found := false
+		for _, row := range rows {
+			if row == marker {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return marker
+		}
+		marker = "EOF" + strconv.Itoa(i)
+	}
+}
+
+// escape value and split it on rows, without splitting escape sequences
+func splitRows(value string) []string {
+	rows := make([]string, 0)
+	var sb strings.Builder
+	rowLen := 0
+	for _, r := range value {
+		if rowLen >= maxRowLen {
+			rows = append(rows, sb.String())
+			sb.Reset()
+			rowLen = 0
+		}
+		if strings.ContainsRune(escapable, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+		rowLen++
+	}
+	return append(rows, sb.String())
+}
+
+// escape runes with special meaning
+func escape(s string) string {
+	if !strings.ContainsAny(s, escapable) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s) + 4)
+	for _, r := range s {
+		if strings.ContainsRune(escapable, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// keys are trimmed and ends at the first =
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty key can not be written")
+	}
+	if strings.ContainsAny(key, "=\n\r") || strings.TrimSpace(key) != key {
+		return fmt.Errorf("key %q can not be written", key)
+	}
+	return nil
+}
+
+// sect names that would be parsed as something else, or with claims, can not be written
+func checkSectName(name string) error {
+	if strings.ContainsAny(name, "#?[]\n\r") || strings.TrimSpace(name) != name ||
+		strings.HasPrefix(name, "define ") || strings.HasPrefix(name, "use ") ||
+		(strings.HasPrefix(name, "include") && strings.Contains(name, "=")) {
+		return fmt.Errorf("sect name %q can not be written", name)
+	}
+	return nil
+}
+
+// writer that counts written bytes and remembers the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) writeString(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
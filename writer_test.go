@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// random config, restricted to what WriteTo can write
+type writableConfig struct {
+	conf *Config
+}
+
+func (writableConfig) Generate(rnd *rand.Rand, size int) reflect.Value {
+	conf := NewConfig()
+	numSects := rnd.Intn(5)
+	seen := make(map[string]bool)
+	for i := 0; i < numSects; i++ {
+		name := randomString(rnd, "abc xyz_.:-\\", 8, false)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		conf.sectNames = append(conf.sectNames, name)
+		props := make(map[string]string)
+		numProps := rnd.Intn(6)
+		for j := 0; j < numProps; j++ {
+			key := randomString(rnd, "abkey$ #[]\\:<", 10, false)
+			if key == "" {
+				continue
+			}
+			// without newlines, long values are written with ending backslashes
+			alphabet := "ab c\t#[]\\=<>:{}$é€"
+			if rnd.Intn(2) == 0 {
+				alphabet += "\n"
+			}
+			props[key] = randomString(rnd, alphabet, 250, true)
+		}
+		if len(props) > 0 {
+			conf.sects[name] = props
+		}
+	}
+	return reflect.ValueOf(writableConfig{conf})
+}
+
+// random string from alphabet. Without space, keys and sect names are trimmed
+func randomString(rnd *rand.Rand, alphabet string, maxLen int, allowSpace bool) string {
+	runes := []rune(alphabet)
+	var sb strings.Builder
+	n := rnd.Intn(maxLen + 1)
+	for i := 0; i < n; i++ {
+		sb.WriteRune(runes[rnd.Intn(len(runes))])
+	}
+	if allowSpace {
+		return sb.String()
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	roundTrip := func(wc writableConfig) bool {
+		var buf bytes.Buffer
+		if _, err := wc.conf.WriteTo(&buf); err != nil {
+			t.Log(err)
+			return false
+		}
+		fileName := filepath.Join(dir, "roundtrip.conf")
+		if err := os.WriteFile(fileName, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		conf, err := NewConfigFromFile(nil, fileName, nil)
+		if err != nil {
+			t.Logf("%v\n%v", err, buf.String())
+			return false
+		}
+		if !reflect.DeepEqual(conf.sects, wc.conf.sects) || !reflect.DeepEqual(conf.sectNames, wc.conf.sectNames) {
+			t.Logf("expected %q %q, got %q %q\n%v", wc.conf.sectNames, wc.conf.sects, conf.sectNames, conf.sects, buf.String())
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	conf := NewConfig()
+	conf.sectNames = []string{"s"}
+	conf.sects["s"] = map[string]string{
+		"escaped":   `50% #1 [a:b] c:\`,
+		"multiLine": "row1\n  row2",
+	}
+	var buf bytes.Buffer
+	if _, err := conf.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[s]\nescaped = 50% \\#1 \\[a:b\\] c:\\\\\nmultiLine = <<EOF\nrow1\n  row2\nEOF\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// backslashes in sect names are escaped
+	conf = NewConfig()
+	if err := conf.SetProp(`a\`, "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if _, err := conf.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := NewConfigFromString(nil, buf.String(), nil)
+	if err != nil {
+		t.Fatalf("%v\n%v", err, buf.String())
+	}
+	if val, _ := reread.PropVal(`a\`, "k"); val != "v" {
+		t.Errorf("sect name with backslash not read back: %q", buf.String())
+	}
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reads the block style subset of yaml needed for config files: maps, lists of scalars, plain and quoted
+// scalars, block scalars (| and >) and flow lists like [a, b]. Anchors, tags, flow maps and multiple
+// documents are not supported
+type yamlParser struct {
+	fr   *formatReader
+	rows []string
+	pos  int
+}
+
+func readYAML(fr *formatReader, data []byte) error {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	yp := &yamlParser{fr: fr, rows: strings.Split(text, "\n")}
+	return yp.parseMap(0, nil)
+}
+
+// index of the next row with content, or -1
+func (yp *yamlParser) nextContent() int {
+	for i := yp.pos; i < len(yp.rows); i++ {
+		row := strings.TrimSpace(yp.rows[i])
+		if row == "" || strings.HasPrefix(row, "#") || row == "---" || row == "..." || strings.HasPrefix(row, "%") {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// number of leading spaces
+func yamlIndent(row string) int {
+	return len(row) - len(strings.TrimLeft(row, " "))
+}
+
+// parse a map where all keys have the given indent. path holds the keys of the parent maps
+func (yp *yamlParser) parseMap(indent int, path []string) error {
+	for {
+		i := yp.nextContent()
+		if i == -1 || yamlIndent(yp.rows[i]) < indent {
+			return nil
+		}
+		row := yp.rows[i]
+		if yamlIndent(row) > indent || strings.HasPrefix(strings.TrimLeft(row, " "), "\t") {
+			return yp.fr.errorAt(i+1, "unexpected indentation")
+		}
+		text := stripYAMLComment(strings.TrimSpace(row))
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			return yp.fr.errorAt(i+1, "list where a map was expected")
+		}
+		key, rest, err := splitYAMLKey(text)
+		if err != nil {
+			return yp.fr.errorAt(i+1, "%v", err)
+		}
+		yp.pos = i + 1
+
+		switch {
+		case rest == "":
+			// a nested map or list, or null
+			next := yp.nextContent()
+			if next != -1 && yamlIndent(yp.rows[next]) > indent {
+				childIndent := yamlIndent(yp.rows[next])
+				childText := strings.TrimSpace(yp.rows[next])
+				if childText == "-" || strings.HasPrefix(childText, "- ") {
+					items, err := yp.parseList(childIndent)
+					if err != nil {
+						return err
+					}
+					if err := yp.setList(path, key, items, i+1); err != nil {
+						return err
+					}
+					continue
+				}
+				childPath := append(append([]string(nil), path...), key)
+				yp.fr.sect(strings.Join(childPath, "."))
+				if err := yp.parseMap(childIndent, childPath); err != nil {
+					return err
+				}
+				continue
+			}
+			// a list may also start at the same indent as its key
+			if next != -1 && yamlIndent(yp.rows[next]) == indent {
+				if childText := strings.TrimSpace(yp.rows[next]); childText == "-" || strings.HasPrefix(childText, "- ") {
+					items, err := yp.parseList(indent)
+					if err != nil {
+						return err
+					}
+					if err := yp.setList(path, key, items, i+1); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if err := yp.setValue(path, key, "", i+1); err != nil {
+				return err
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			value, err := yp.parseBlockScalar(rest, indent)
+			if err != nil {
+				return yp.fr.errorAt(i+1, "%v", err)
+			}
+			if err := yp.setValue(path, key, value, i+1); err != nil {
+				return err
+			}
+		case rest[0] == '[':
+			items, err := parseYAMLFlowList(rest)
+			if err != nil {
+				return yp.fr.errorAt(i+1, "%v", err)
+			}
+			if err := yp.setList(path, key, items, i+1); err != nil {
+				return err
+			}
+		default:
+			value, err := parseYAMLValue(rest)
+			if err != nil {
+				return yp.fr.errorAt(i+1, "%v", err)
+			}
+			if err := yp.setValue(path, key, value, i+1); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// top level scalars go in the sect named "", the rest in the sect named by path
+func (yp *yamlParser) setValue(path []string, key, value string, row int) error {
+	return yp.fr.prop(strings.Join(path, "."), key, value, row)
+}
+
+// lists become list props, in the same sect as scalars would
+func (yp *yamlParser) setList(path []string, key string, items []string, row int) error {
+	return yp.fr.list(strings.Join(path, "."), key, items, row)
+}
+
+// parse list items with the given indent
+func (yp *yamlParser) parseList(indent int) ([]string, error) {
+	items := make([]string, 0)
+	for {
+		i := yp.nextContent()
+		if i == -1 || yamlIndent(yp.rows[i]) != indent {
+			break
+		}
+		text := stripYAMLComment(strings.TrimSpace(yp.rows[i]))
+		if text != "-" && !strings.HasPrefix(text, "- ") {
+			break
+		}
+		yp.pos = i + 1
+		item, err := parseYAMLScalar(strings.TrimSpace(text[1:]))
+		if err != nil {
+			return nil, yp.fr.errorAt(i+1, "%v", err)
+		}
+		items = append(items, item)
+	}
+	if next := yp.nextContent(); next != -1 && yamlIndent(yp.rows[next]) > indent {
+		return nil, yp.fr.errorAt(next+1, "only lists of scalars are supported")
+	}
+	return items, nil
+}
+
+// parse | and > block scalars, with optional - and + chomping indicators
+func (yp *yamlParser) parseBlockScalar(header string, indent int) (string, error) {
+	style := header[0]
+	chomp := strings.TrimSpace(stripYAMLComment(header[1:]))
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", fmt.Errorf("unsupported block scalar header %v", header)
+	}
+
+	// collect rows indented more than the key, and empty rows
+	lines := make([]string, 0)
+	blockIndent := -1
+	for ; yp.pos < len(yp.rows); yp.pos++ {
+		row := yp.rows[yp.pos]
+		if strings.TrimSpace(row) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if yamlIndent(row) <= indent || (blockIndent != -1 && yamlIndent(row) < blockIndent) {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = yamlIndent(row)
+		}
+		lines = append(lines, row[blockIndent:])
+	}
+	content := len(lines)
+	for content > 0 && lines[content-1] == "" {
+		content--
+	}
+
+	var sb strings.Builder
+	for i, line := range lines[:content] {
+		switch {
+		case i == 0:
+		case style == '|':
+			sb.WriteRune('\n')
+		case line == "":
+			// in folded style, empty rows are line breaks
+			sb.WriteRune('\n')
+		case lines[i-1] == "":
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+			// more indented rows are not folded
+			sb.WriteRune('\n')
+		default:
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(line)
+	}
+
+	// clip keeps the last line break, strip removes it and keep keeps trailing empty rows too
+	if content > 0 && chomp != "-" {
+		sb.WriteRune('\n')
+	}
+	if chomp == "+" {
+		sb.WriteString(strings.Repeat("\n", len(lines)-content))
+	}
+	return sb.String(), nil
+}
+
+// split "key: value" into key and value
+func splitYAMLKey(text string) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end == -1 {
+			return "", "", fmt.Errorf("unterminated key %v", text)
+		}
+		key, err := parseYAMLScalar(text[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		rest := strings.TrimSpace(text[end+1:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected : after key %v", key)
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+	if i := strings.Index(text, ": "); i != -1 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), nil
+	}
+	if strings.HasSuffix(text, ":") {
+		return strings.TrimSpace(text[:len(text)-1]), "", nil
+	}
+	return "", "", fmt.Errorf("expected key: value, got %v", text)
+}
+
+// parse a value, that is not a flow list
+func parseYAMLValue(text string) (string, error) {
+	if strings.HasPrefix(text, "{") {
+		return "", fmt.Errorf("flow maps are not supported")
+	}
+	return parseYAMLScalar(text)
+}
+
+// parse a flow list like [a, b]
+func parseYAMLFlowList(text string) ([]string, error) {
+	if !strings.HasSuffix(text, "]") {
+		return nil, fmt.Errorf("flow list without ]")
+	}
+	items := make([]string, 0)
+	inner := strings.TrimSpace(text[1 : len(text)-1])
+	for inner != "" {
+		end := strings.IndexRune(inner, ',')
+		if inner[0] == '"' || inner[0] == '\'' {
+			end = closingQuote(inner) + 1
+			if end == 0 {
+				return nil, fmt.Errorf("unterminated string %v", inner)
+			}
+		} else if end == -1 {
+			end = len(inner)
+		}
+		item, err := parseYAMLScalar(strings.TrimSpace(inner[:end]))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		inner = strings.TrimSpace(inner[end:])
+		if strings.HasPrefix(inner, ",") {
+			inner = strings.TrimSpace(inner[1:])
+		} else if inner != "" {
+			return nil, fmt.Errorf("expected , in flow list")
+		}
+	}
+	return items, nil
+}
+
+// parse a plain, single or double quoted scalar. Null becomes an empty string
+func parseYAMLScalar(text string) (string, error) {
+	switch {
+	case text == "" || text == "~" || text == "null" || text == "Null" || text == "NULL":
+		return "", nil
+	case text[0] == '"':
+		if closingQuote(text) != len(text)-1 {
+			return "", fmt.Errorf("unterminated string %v", text)
+		}
+		return strconv.Unquote(text)
+	case text[0] == '\'':
+		if closingQuote(text) != len(text)-1 {
+			return "", fmt.Errorf("unterminated string %v", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text[0] == '&' || text[0] == '*' || text[0] == '!':
+		return "", fmt.Errorf("anchors, aliases and tags are not supported")
+	}
+	return text, nil
+}
+
+// index of the quote ending the quoted string starting text, or -1
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// remove a comment, which starts with # after a space, outside quotes
+func stripYAMLComment(text string) string {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			// quotes only start a string at the beginning of a value
+			if i == 0 || strings.ContainsRune(" [,", rune(text[i-1])) {
+				if end := closingQuote(text[i:]); end != -1 {
+					i += end
+				}
+			}
+		case '#':
+			if i == 0 || text[i-1] == ' ' || text[i-1] == '\t' {
+				return strings.TrimSpace(text[:i])
+			}
+		}
+	}
+	return text
+}